@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"time"
+)
+
+// Archive is an in-memory .tar.gz with namespace manifests.
+type Archive struct {
+	buffer    *bytes.Buffer
+	gzWriter  *gzip.Writer
+	tarWriter *tar.Writer
+	createdAt time.Time
+}
+
+func NewArchive() *Archive {
+	buffer := new(bytes.Buffer)
+	gzWriter := gzip.NewWriter(buffer)
+
+	return &Archive{
+		buffer:    buffer,
+		gzWriter:  gzWriter,
+		tarWriter: tar.NewWriter(gzWriter),
+		createdAt: time.Now(),
+	}
+}
+
+func (a *Archive) AddFile(name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: a.createdAt,
+	}
+	if err := a.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := a.tarWriter.Write(data)
+	return err
+}
+
+// Bytes finalizes the archive, no files can be added after it.
+func (a *Archive) Bytes() ([]byte, error) {
+	if err := a.tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := a.gzWriter.Close(); err != nil {
+		return nil, err
+	}
+	return a.buffer.Bytes(), nil
+}
@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Object is a stored archive.
+type Object struct {
+	Name         string
+	LastModified time.Time
+}
+
+// Store keeps namespace archives, either on a mounted volume or in an S3-compatible bucket.
+type Store interface {
+	Put(ctx context.Context, name string, data []byte) error
+	List(ctx context.Context) ([]Object, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// DirStore keeps archives as plain files in a directory.
+type DirStore struct {
+	dir string
+}
+
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (d *DirStore) Put(ctx context.Context, name string, data []byte) error {
+	tmpName := filepath.Join(d.dir, "."+name+".tmp")
+	file, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, bytes.NewReader(data)); err != nil {
+		file.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, filepath.Join(d.dir, name))
+}
+
+func (d *DirStore) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == ".tmp" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, Object{Name: entry.Name(), LastModified: info.ModTime()})
+	}
+	return objects, nil
+}
+
+func (d *DirStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(d.dir, name))
+}
+
+// Prune deletes archives older than maxAge and returns names of deleted ones.
+// Zero maxAge means archives are kept forever.
+func Prune(ctx context.Context, store Store, maxAge time.Duration) ([]string, error) {
+	pruned := make([]string, 0)
+	if maxAge <= 0 {
+		return pruned, nil
+	}
+
+	objects, err := store.List(ctx)
+	if err != nil {
+		return pruned, err
+	}
+
+	threshold := time.Now().Add(-maxAge)
+	for _, object := range objects {
+		if object.LastModified.After(threshold) {
+			continue
+		}
+		if err := store.Delete(ctx, object.Name); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, object.Name)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,338 @@
+// kubectl-reaper is a kubectl plugin showing and changing what ReviewReaper is going to do
+// with review namespaces. It loads the same config as the reaper and runs client-side.
+package main
+
+import (
+	"NaNameUz3r/ReviewReaper/namespaces_informer"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+)
+
+const (
+	OUTPUT_TABLE = "table"
+	OUTPUT_JSON  = "json"
+	OUTPUT_YAML  = "yaml"
+)
+
+type plugin struct {
+	configFlags *genericclioptions.ConfigFlags
+	configPath  string
+	output      string
+
+	client  *kubernetes.Clientset
+	reaper  *namespaces_informer.NsInformer
+	streams genericclioptions.IOStreams
+}
+
+func main() {
+	p := &plugin{
+		configFlags: genericclioptions.NewConfigFlags(false),
+		streams:     genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr},
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := p.command().ExecuteContext(ctx); err != nil {
+		cancel()
+		os.Exit(1)
+	}
+}
+
+func (p *plugin) command() *cobra.Command {
+	root := &cobra.Command{
+		Use:               "kubectl-reaper",
+		Short:             "Show and change what ReviewReaper is going to do with review namespaces",
+		SilenceUsage:      true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return p.setup(cmd.Context()) },
+	}
+	p.configFlags.AddFlags(root.PersistentFlags())
+	root.PersistentFlags().StringVar(&p.configPath, "config", "", "ReviewReaper config file, looked up in /etc/app, /app and the current directory by default")
+	root.PersistentFlags().StringVarP(&p.output, "output", "o", OUTPUT_TABLE, "Output format: table, json or yaml")
+
+	root.AddCommand(
+		p.listCommand(),
+		&cobra.Command{
+			Use:   "explain NAMESPACE",
+			Short: "Explain why the namespace is or is not watched and when it is going to be deleted",
+			Args:  cobra.ExactArgs(1),
+			RunE:  p.explain,
+		},
+		&cobra.Command{
+			Use:     "extend NAMESPACE DURATION",
+			Short:   "Move the deletion time forward, like 2d or 36h",
+			Example: "  kubectl reaper extend feature-123 2d",
+			Args:    cobra.ExactArgs(2),
+			RunE:    p.extend,
+		},
+		p.protectCommand(),
+		&cobra.Command{
+			Use:   "unprotect NAMESPACE",
+			Short: "Remove the protection of the namespace",
+			Args:  cobra.ExactArgs(1),
+			RunE:  p.unprotect,
+		},
+	)
+	return root
+}
+
+func (p *plugin) setup(ctx context.Context) error {
+	if !utils.IsContains([]string{OUTPUT_TABLE, OUTPUT_JSON, OUTPUT_YAML}, p.output) {
+		return fmt.Errorf("unknown output format %q", p.output)
+	}
+
+	loadConfig := utils.LoadConfig
+	if p.configPath != "" {
+		loadConfig = func() (utils.Config, error) { return utils.LoadConfigFile(p.configPath) }
+	}
+	appConfig, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("could not load ReviewReaper config: %w", err)
+	}
+
+	restConfig, err := p.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   "kubectl-reaper",
+		Level:  hclog.Error,
+		Output: p.streams.ErrOut,
+	})
+	p.client = client
+	p.reaper = namespaces_informer.NewNsInformer(restConfig, client, logger, appConfig)
+	return p.reaper.LoadPolicies(ctx)
+}
+
+func (p *plugin) listCommand() *cobra.Command {
+	protectedOnly := false
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List watched namespaces with their deletion time and status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return p.list(cmd.Context(), protectedOnly)
+		},
+	}
+	command.Flags().BoolVar(&protectedOnly, "protected", false, "List only protected namespaces with their protection reason and expiry")
+	return command
+}
+
+func (p *plugin) list(ctx context.Context, protectedOnly bool) error {
+	list, err := p.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	namespaces := make([]*corev1.Namespace, 0, len(list.Items))
+	for i := range list.Items {
+		namespaces = append(namespaces, &list.Items[i])
+	}
+
+	statuses := p.reaper.NsStatuses(namespaces)
+	if protectedOnly {
+		protected := make([]namespaces_informer.NsStatus, 0)
+		for _, status := range statuses {
+			if status.Protection != nil {
+				protected = append(protected, status)
+			}
+		}
+		statuses = protected
+	}
+	if p.output != OUTPUT_TABLE {
+		return p.print(statuses)
+	}
+
+	w := tabwriter.NewWriter(p.streams.Out, 0, 4, 3, ' ', 0)
+	if protectedOnly {
+		fmt.Fprintln(w, "NAME\tOWNER\tPROTECTED UNTIL\tREASON")
+		for _, status := range statuses {
+			fmt.Fprintf(
+				w,
+				"%s\t%s\t%s\t%s\n",
+				status.Name,
+				orNone(status.Owner),
+				orNone(status.Protection.Until),
+				orNone(status.Protection.Reason),
+			)
+		}
+		return w.Flush()
+	}
+
+	fmt.Fprintln(w, "NAME\tSTATUS\tOWNER\tDELETE AFTER\tEXPIRED BY")
+	for _, status := range statuses {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\n",
+			status.Name,
+			status.Status,
+			orNone(status.Owner),
+			orNone(status.DeleteAfter),
+			orNone(status.ExpiredBy),
+		)
+	}
+	return w.Flush()
+}
+
+func (p *plugin) explain(cmd *cobra.Command, args []string) error {
+	ns, err := p.getNamespace(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	explanation := p.reaper.Explain(ns)
+	if p.output != OUTPUT_TABLE {
+		return p.print(explanation)
+	}
+
+	w := tabwriter.NewWriter(p.streams.Out, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", explanation.Name)
+	fmt.Fprintf(w, "Status:\t%s\n", explanation.Status)
+	fmt.Fprintf(w, "Owner:\t%s\n", orNone(explanation.Owner))
+	fmt.Fprintf(w, "Delete after:\t%s\n", orNone(explanation.DeleteAfter))
+	if protection := explanation.Protection; protection != nil {
+		fmt.Fprintf(w, "Protected until:\t%s\n", orNone(protection.Until))
+		fmt.Fprintf(w, "Protection reason:\t%s\n", orNone(protection.Reason))
+	}
+	fmt.Fprintf(w, "Estimated deletion:\t%s\n", orNone(explanation.EstimatedDeletion))
+	policySource := "config"
+	if explanation.Policy.Name != "" {
+		policySource = "ReaperPolicy " + explanation.Policy.Name
+	}
+	fmt.Fprintf(
+		w,
+		"Policy:\t%s, retention %s, postpone by Helm deploy %t, uninstall releases %t\n",
+		policySource,
+		explanation.Policy.Retention,
+		explanation.Policy.PostponeByHelmDeploy,
+		explanation.Policy.UninstallReleases,
+	)
+	fmt.Fprintln(w, "Reasons:")
+	for _, reason := range explanation.Reasons {
+		fmt.Fprintf(w, "  - %s\n", reason)
+	}
+	return w.Flush()
+}
+
+func (p *plugin) extend(cmd *cobra.Command, args []string) error {
+	duration, err := utils.ParseDuration(args[1])
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("invalid duration %q", args[1])
+	}
+	return p.change(cmd.Context(), args[0], func(ctx context.Context, ns *corev1.Namespace) error {
+		return p.reaper.Extend(ctx, ns, duration)
+	})
+}
+
+func (p *plugin) protectCommand() *cobra.Command {
+	reason := ""
+	duration := ""
+	command := &cobra.Command{
+		Use:     "protect NAMESPACE",
+		Short:   "Protect the namespace from deletion, permanently or for a duration",
+		Example: "  kubectl reaper protect feature-123 --reason \"demo for the customer\" --for 7d",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			until := time.Time{}
+			if duration != "" {
+				forDuration, err := utils.ParseDuration(duration)
+				if err != nil || forDuration <= 0 {
+					return fmt.Errorf("invalid duration %q", duration)
+				}
+				until = time.Now().UTC().Add(forDuration)
+			}
+			return p.change(cmd.Context(), args[0], func(ctx context.Context, ns *corev1.Namespace) error {
+				return p.reaper.Protect(ctx, ns, reason, until)
+			})
+		},
+	}
+	command.Flags().StringVar(&reason, "reason", "", "Why the namespace is protected")
+	command.Flags().StringVar(&duration, "for", "", "Lift the protection after the duration, like 7d, the protection is permanent otherwise")
+	command.MarkFlagRequired("reason")
+	return command
+}
+
+func (p *plugin) unprotect(cmd *cobra.Command, args []string) error {
+	return p.change(cmd.Context(), args[0], p.reaper.Unprotect)
+}
+
+// change applies the change to a namespace matching the deletion regexp and prints its new status.
+func (p *plugin) change(
+	ctx context.Context,
+	name string,
+	apply func(context.Context, *corev1.Namespace) error,
+) error {
+	ns, err := p.getNamespace(ctx, name)
+	if err != nil {
+		return err
+	}
+	if status := p.reaper.NsStatus(ns); status.Status == namespaces_informer.NS_STATUS_UNWATCHED {
+		return fmt.Errorf("namespace %q does not match NsNameDeletionRegexp and no ReaperPolicy selects it", name)
+	}
+	if err := apply(ctx, ns); err != nil {
+		return err
+	}
+
+	ns, err = p.getNamespace(ctx, name)
+	if err != nil {
+		return err
+	}
+	status := p.reaper.NsStatus(ns)
+	if p.output != OUTPUT_TABLE {
+		return p.print(status)
+	}
+	fmt.Fprintf(p.streams.Out, "namespace/%s %s, delete after %s\n", status.Name, status.Status, orNone(status.DeleteAfter))
+	return nil
+}
+
+func (p *plugin) getNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	return p.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+func (p *plugin) print(value interface{}) error {
+	return printAs(p.streams.Out, p.output, value)
+}
+
+func printAs(out io.Writer, format string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == OUTPUT_YAML {
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(out, strings.TrimSuffix(string(data), "\n"))
+	return err
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
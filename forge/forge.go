@@ -0,0 +1,140 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	GITHUB = "github"
+	GITLAB = "gitlab"
+	GITEA  = "gitea"
+
+	PAGE_SIZE       = 100
+	REQUEST_TIMEOUT = 30 * time.Second
+)
+
+var KnownTypes = []string{GITHUB, GITLAB, GITEA}
+
+var defaultBaseURLs = map[string]string{
+	GITHUB: "https://api.github.com",
+	GITLAB: "https://gitlab.com",
+	GITEA:  "https://gitea.com",
+}
+
+// Client lists branches of a single repository from a Git forge REST API.
+type Client struct {
+	forgeType  string
+	baseURL    string
+	repository string
+	token      string
+	httpClient *http.Client
+}
+
+type branch struct {
+	Name string `json:"name"`
+}
+
+func NewClient(forgeType, baseURL, repository, token string) (*Client, error) {
+	forgeType = strings.ToLower(forgeType)
+	defaultBaseURL, ok := defaultBaseURLs[forgeType]
+	if !ok {
+		return nil, fmt.Errorf("unknown forge type %q, expected one of %v", forgeType, KnownTypes)
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	return &Client{
+		forgeType:  forgeType,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		repository: repository,
+		token:      token,
+		httpClient: &http.Client{Timeout: REQUEST_TIMEOUT},
+	}, nil
+}
+
+// ListBranches returns names of all existing branches of the repository, walking through all pages.
+// Pages are requested until an empty one, because forges may silently cap the page size.
+func (c *Client) ListBranches(ctx context.Context) ([]string, error) {
+	branches := make([]string, 0)
+
+	for page := 1; ; page++ {
+		pageBranches, err := c.listBranchesPage(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(pageBranches) == 0 {
+			break
+		}
+		for _, b := range pageBranches {
+			branches = append(branches, b.Name)
+		}
+	}
+
+	return branches, nil
+}
+
+func (c *Client) listBranchesPage(ctx context.Context, page int) ([]branch, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.branchesURL(page), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(req)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s while listing branches of %s", c.forgeType, resp.Status, c.repository)
+	}
+
+	pageBranches := make([]branch, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&pageBranches); err != nil {
+		return nil, err
+	}
+	return pageBranches, nil
+}
+
+func (c *Client) branchesURL(page int) string {
+	switch c.forgeType {
+	case GITLAB:
+		return fmt.Sprintf(
+			"%s/api/v4/projects/%s/repository/branches?per_page=%d&page=%d",
+			c.baseURL, url.PathEscape(c.repository), PAGE_SIZE, page,
+		)
+	case GITEA:
+		return fmt.Sprintf(
+			"%s/api/v1/repos/%s/branches?limit=%d&page=%d",
+			c.baseURL, c.repository, PAGE_SIZE, page,
+		)
+	default:
+		return fmt.Sprintf(
+			"%s/repos/%s/branches?per_page=%d&page=%d",
+			c.baseURL, c.repository, PAGE_SIZE, page,
+		)
+	}
+}
+
+func (c *Client) authorize(req *http.Request) {
+	if c.token == "" {
+		return
+	}
+	switch c.forgeType {
+	case GITLAB:
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+	case GITEA:
+		req.Header.Set("Authorization", "token "+c.token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
@@ -0,0 +1,133 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// newForgeServer serves the branches in pages of pageSize at the path, like a forge capping the page size.
+func newForgeServer(t *testing.T, path string, pageSize int, branches []string, requests *[]*http.Request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r)
+		if r.URL.EscapedPath() != path {
+			http.NotFound(w, r)
+			return
+		}
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			http.Error(w, "bad page", http.StatusBadRequest)
+			return
+		}
+
+		pageBranches := make([]branch, 0)
+		for i := (page - 1) * pageSize; i < len(branches) && i < page*pageSize; i++ {
+			pageBranches = append(pageBranches, branch{Name: branches[i]})
+		}
+		json.NewEncoder(w).Encode(pageBranches)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestListBranches(t *testing.T) {
+	branches := []string{"main", "feature/a", "feature/b", "feature/c", "fix/d"}
+
+	tests := []struct {
+		forgeType  string
+		path       string
+		authHeader string
+		authValue  string
+	}{
+		{
+			forgeType:  GITHUB,
+			path:       "/repos/acme/shop/branches",
+			authHeader: "Authorization",
+			authValue:  "Bearer t0ken",
+		},
+		{
+			forgeType:  GITEA,
+			path:       "/api/v1/repos/acme/shop/branches",
+			authHeader: "Authorization",
+			authValue:  "token t0ken",
+		},
+		{
+			forgeType:  GITLAB,
+			path:       "/api/v4/projects/acme%2Fshop/repository/branches",
+			authHeader: "PRIVATE-TOKEN",
+			authValue:  "t0ken",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.forgeType, func(t *testing.T) {
+			requests := make([]*http.Request, 0)
+			server := newForgeServer(t, tt.path, 2, branches, &requests)
+
+			client, err := NewClient(tt.forgeType, server.URL+"/", "acme/shop", "t0ken")
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed, err := client.ListBranches(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(listed, branches) {
+				t.Errorf("expected branches %v, got %v", branches, listed)
+			}
+
+			// Three full or partial pages and the empty one ending the walk.
+			if len(requests) != 4 {
+				t.Errorf("expected 4 page requests, got %d", len(requests))
+			}
+			for _, r := range requests {
+				if value := r.Header.Get(tt.authHeader); value != tt.authValue {
+					t.Errorf("expected %s %q, got %q", tt.authHeader, tt.authValue, value)
+				}
+			}
+		})
+	}
+}
+
+func TestListBranchesEmpty(t *testing.T) {
+	requests := make([]*http.Request, 0)
+	server := newForgeServer(t, "/repos/acme/shop/branches", PAGE_SIZE, nil, &requests)
+
+	client, err := NewClient(GITHUB, server.URL, "acme/shop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, err := client.ListBranches(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 0 {
+		t.Errorf("expected no branches, got %v", listed)
+	}
+	if value := requests[0].Header.Get("Authorization"); value != "" {
+		t.Errorf("expected no Authorization without a token, got %q", value)
+	}
+}
+
+func TestListBranchesError(t *testing.T) {
+	requests := make([]*http.Request, 0)
+	server := newForgeServer(t, "/repos/acme/shop/branches", PAGE_SIZE, []string{"main"}, &requests)
+
+	client, err := NewClient(GITHUB, server.URL, "acme/missing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListBranches(context.Background()); err == nil {
+		t.Error("expected an error for a missing repository")
+	}
+}
+
+func TestNewClientUnknownType(t *testing.T) {
+	if _, err := NewClient("bitbucket", "", "acme/shop", ""); err == nil {
+		t.Error("expected an error for an unknown forge type")
+	}
+}
@@ -21,11 +21,15 @@ func NewLogger(appConfig utils.Config) hclog.Logger {
 
 func StartUp(appConfig utils.Config, logger hclog.Logger) {
 	logger.Info(printConfig(appConfig))
+	logger.Info(printConfigSources(utils.ConfigSources()))
+	for _, warning := range utils.ConfigDeprecations() {
+		logger.Warn(warning)
+	}
 	logger.Info("Verifying connection and attempting to initiate reconciliation loop...")
 }
 
 func printConfig(s interface{}) string {
-	hiddenFields := []string{"DeletionRegexp"}
+	hiddenFields := []string{"DeletionRegexp", "Secret", "Token", "SecretKey"}
 	structValue := reflect.ValueOf(s)
 
 	config := fmt.Sprintf("\n\n%v:\n", "Loaded config")
@@ -35,6 +39,14 @@ func printConfig(s interface{}) string {
 	return config
 }
 
+func printConfigSources(sources []utils.ConfigSource) string {
+	config := "\n\nConfig value sources (flag > env > file > default):\n"
+	for _, source := range sources {
+		config += fmt.Sprintf("\t%s: %s (%s)\n", source.Key, source.Source, source.EnvVar)
+	}
+	return config
+}
+
 func printFields(value reflect.Value, hiddenFields []string, indent string, config *string) {
 	structType := value.Type()
 	for i := 0; i < structType.NumField(); i++ {
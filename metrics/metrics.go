@@ -0,0 +1,77 @@
+// Package metrics holds the Prometheus metrics ReviewReaper exposes about itself.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	RELOAD_SUCCESS = "success"
+	RELOAD_FAILURE = "failure"
+)
+
+var (
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "review_reaper_config_reloads_total",
+			Help: "Config reloads by result.",
+		},
+		[]string{"result"},
+	)
+	ConfigLastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "review_reaper_config_last_reload_successful",
+		Help: "Whether the last config reload succeeded, 0 means the previous config is still in use.",
+	})
+	ConfigLastReloadSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "review_reaper_config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful config load.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(ConfigReloads, ConfigLastReloadSuccessful, ConfigLastReloadSuccessTimestamp)
+	ConfigLastReloadSuccessful.Set(1)
+	ConfigLastReloadSuccessTimestamp.SetToCurrentTime()
+}
+
+// ConfigReloaded records the result of a config reload.
+func ConfigReloaded(err error) {
+	if err != nil {
+		ConfigReloads.WithLabelValues(RELOAD_FAILURE).Inc()
+		ConfigLastReloadSuccessful.Set(0)
+		return
+	}
+	ConfigReloads.WithLabelValues(RELOAD_SUCCESS).Inc()
+	ConfigLastReloadSuccessful.Set(1)
+	ConfigLastReloadSuccessTimestamp.SetToCurrentTime()
+}
+
+// Serve exposes the metrics on /metrics until the context is done.
+func Serve(ctx context.Context, listenAddress string, logger hclog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:              listenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Serving metrics", "ListenAddress", listenAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Metrics server failed", "ERROR:", err)
+	}
+}
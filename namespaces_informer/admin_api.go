@@ -0,0 +1,244 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	ADMIN_API_PREFIX         = "/api/v1/"
+	ADMIN_API_MAX_BODY_BYTES = 1 << 16
+	ADMIN_API_EXPIRED_BY     = "admin-api"
+)
+
+var errNsNotWatched = errors.New("namespace is not watched")
+
+type maintenanceStatus struct {
+	InWindow   bool             `json:"inWindow"`
+	NextWindow string           `json:"nextWindow"`
+	Window     utils.TimeWindow `json:"window"`
+}
+
+type extendRequest struct {
+	Duration string `json:"duration"`
+}
+
+// protectRequest protects the namespace for the reason, for the duration if it is set or permanently.
+type protectRequest struct {
+	Reason   string `json:"reason"`
+	Duration string `json:"duration,omitempty"`
+}
+
+func (n *NsInformer) serveAdminAPI(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle(ADMIN_API_PREFIX, n.requireAdminToken(n.handleAdminAPI(ctx)))
+
+	server := &http.Server{
+		Addr:              n.config().AdminAPI.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	n.logger.Info("Serving admin API", "Address", server.Addr, "Path", ADMIN_API_PREFIX)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		n.logger.Error("Admin API stopped", "ERROR:", err)
+	}
+}
+
+func (n *NsInformer) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(n.config().AdminAPI.Token)) != 1 {
+			n.logger.Warn("Rejected admin API request with invalid token", "RemoteAddr", r.RemoteAddr)
+			writeJSONError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAdminAPI routes:
+//
+//	GET  /api/v1/namespaces[?status=<status>]
+//	GET  /api/v1/namespaces/<name>
+//	POST /api/v1/namespaces/<name>/{extend,protect,unprotect,expire}
+//	GET  /api/v1/maintenance
+func (n *NsInformer) handleAdminAPI(ctx context.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, ADMIN_API_PREFIX), "/")
+		parts := strings.Split(path, "/")
+
+		switch {
+		case path == "maintenance" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, n.maintenanceStatus())
+		case path == "namespaces" && r.Method == http.MethodGet:
+			namespaces, err := n.nsLister.List(labels.Everything())
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, errors.New("could not list namespaces"))
+				return
+			}
+			writeJSON(w, http.StatusOK, filterNsStatuses(n.NsStatuses(namespaces), r.URL.Query().Get("status")))
+		case len(parts) == 2 && parts[0] == "namespaces" && r.Method == http.MethodGet:
+			ns, err := n.getManagedNamespace(ctx, parts[1])
+			if err != nil {
+				writeNsError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, n.NsStatus(ns))
+		case len(parts) == 3 && parts[0] == "namespaces" && r.Method == http.MethodPost:
+			n.handleNsAction(ctx, w, r, parts[1], parts[2])
+		default:
+			writeJSONError(w, http.StatusNotFound, errors.New("not found"))
+		}
+	}
+}
+
+func (n *NsInformer) handleNsAction(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	name string,
+	action string,
+) {
+	ns, err := n.getManagedNamespace(ctx, name)
+	if err != nil {
+		writeNsError(w, err)
+		return
+	}
+
+	// Requests are validated in dry run too, only the change is skipped.
+	var change func() error
+	switch action {
+	case "extend":
+		request := extendRequest{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, ADMIN_API_MAX_BODY_BYTES)).Decode(&request); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		duration, err := utils.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid duration %q", request.Duration))
+			return
+		}
+		change = func() error { return n.Extend(ctx, ns, duration) }
+	case "protect":
+		request := protectRequest{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, ADMIN_API_MAX_BODY_BYTES)).Decode(&request); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		if request.Reason == "" {
+			writeJSONError(w, http.StatusBadRequest, errProtectionReasonMissing)
+			return
+		}
+		until := time.Time{}
+		if request.Duration != "" {
+			duration, err := utils.ParseDuration(request.Duration)
+			if err != nil || duration <= 0 {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid duration %q", request.Duration))
+				return
+			}
+			until = time.Now().UTC().Add(duration)
+		}
+		change = func() error { return n.Protect(ctx, ns, request.Reason, until) }
+	case "unprotect":
+		change = func() error { return n.Unprotect(ctx, ns) }
+	case "expire":
+		change = func() error { return n.expireNamespace(ctx, ns, ADMIN_API_EXPIRED_BY) }
+	default:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("unknown action %q", action))
+		return
+	}
+
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to change via admin API", "namespace", ns.Name, "Action", action)
+		writeJSON(w, http.StatusOK, n.NsStatus(ns))
+		return
+	}
+	if err := change(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	n.logger.Info("Namespace changed via admin API", "namespace", ns.Name, "Action", action)
+
+	ns, err = n.getManagedNamespace(ctx, name)
+	if err != nil {
+		writeNsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, n.NsStatus(ns))
+}
+
+// getManagedNamespace reads the namespace from the API server rather than from the lister,
+// so the response reflects the changes just made.
+func (n *NsInformer) getManagedNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	ns, err := n.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !n.isSelected(ns) {
+		return nil, errNsNotWatched
+	}
+	return ns, nil
+}
+
+func (n *NsInformer) maintenanceStatus() maintenanceStatus {
+	status := maintenanceStatus{
+		InWindow: n.isNowAllowed(),
+		Window:   n.config().DeletionWindow,
+	}
+	status.NextWindow = n.nextWindowStart(time.Now().UTC()).Format(time.RFC3339)
+	return status
+}
+
+// filterNsStatuses keeps statuses with the status name, all of them if it is empty.
+func filterNsStatuses(statuses []NsStatus, status string) []NsStatus {
+	if status == "" {
+		return statuses
+	}
+	filtered := make([]NsStatus, 0)
+	for _, nsStatus := range statuses {
+		if nsStatus.Status == status {
+			filtered = append(filtered, nsStatus)
+		}
+	}
+	return filtered
+}
+
+func writeNsError(w http.ResponseWriter, err error) {
+	if apierrors.IsNotFound(err) || errors.Is(err, errNsNotWatched) {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
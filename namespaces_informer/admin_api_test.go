@@ -0,0 +1,109 @@
+package namespaces_informer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceStatusNextWindow(t *testing.T) {
+	now := time.Now().UTC()
+	windowDay := now.AddDate(0, 0, 2)
+
+	config := newTestConfig()
+	config.DeletionWindow.NotBefore = "03:00"
+	config.DeletionWindow.NotAfter = "04:00"
+	config.DeletionWindow.WeekDays = []string{windowDay.Format("Mon")}
+	n, _ := newTestInformer(t, config)
+
+	status := n.maintenanceStatus()
+	expected := time.Date(windowDay.Year(), windowDay.Month(), windowDay.Day(), 3, 0, 0, 0, time.UTC)
+	if status.InWindow || status.NextWindow != expected.Format(time.RFC3339) {
+		t.Errorf("expected the closed window to open at %s, got %+v", expected.Format(time.RFC3339), status)
+	}
+}
+
+func TestAdminAPIExpire(t *testing.T) {
+	tests := []struct {
+		name      string
+		dryRun    bool
+		expiredBy string
+	}{
+		{name: "expire", expiredBy: ADMIN_API_EXPIRED_BY},
+		{name: "dry run", dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.DryRun = tt.dryRun
+			deleteAfter := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
+			n, client := newTestInformer(t, config, newTestNamespace("review-a", map[string]string{"delete_after": deleteAfter}))
+
+			request := httptest.NewRequest(http.MethodPost, ADMIN_API_PREFIX+"namespaces/review-a/expire", nil)
+			recorder := httptest.NewRecorder()
+			n.handleAdminAPI(context.Background())(recorder, request)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body)
+			}
+			status := NsStatus{}
+			if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+
+			ns := getTestNamespace(t, client, "review-a")
+			if expiredBy := ns.Annotations[config.NsExpiredByAnnotation]; expiredBy != tt.expiredBy {
+				t.Errorf("expected expired by %q, got %q", tt.expiredBy, expiredBy)
+			}
+			if tt.dryRun && ns.Annotations["delete_after"] != deleteAfter {
+				t.Errorf("expected the deletion time to be kept in dry run, got %s", ns.Annotations["delete_after"])
+			}
+		})
+	}
+}
+
+func TestAdminAPIDryRun(t *testing.T) {
+	tests := []struct {
+		action     string
+		body       string
+		statusCode int
+	}{
+		{action: "extend", body: `{"duration": "2d"}`, statusCode: http.StatusOK},
+		{action: "extend", body: `{"duration": "soon"}`, statusCode: http.StatusBadRequest},
+		{action: "protect", body: `{"reason": "demo", "duration": "7d"}`, statusCode: http.StatusOK},
+		{action: "protect", body: `{}`, statusCode: http.StatusBadRequest},
+		{action: "unprotect", statusCode: http.StatusOK},
+		{action: "expire", statusCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+" "+tt.body, func(t *testing.T) {
+			config := newTestConfig()
+			config.DryRun = true
+			annotations := map[string]string{
+				"delete_after":                    time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339),
+				config.NsProtectedUntilAnnotation: time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+			}
+			n, client := newTestInformer(t, config, newTestNamespace("review-a", annotations))
+
+			request := httptest.NewRequest(
+				http.MethodPost,
+				ADMIN_API_PREFIX+"namespaces/review-a/"+tt.action,
+				strings.NewReader(tt.body),
+			)
+			recorder := httptest.NewRecorder()
+			n.handleAdminAPI(context.Background())(recorder, request)
+
+			if recorder.Code != tt.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.statusCode, recorder.Code, recorder.Body)
+			}
+			if ns := getTestNamespace(t, client, "review-a"); !reflect.DeepEqual(ns.Annotations, annotations) {
+				t.Errorf("expected the annotations to be kept in dry run, got %v", ns.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ADMISSION_VALIDATE_PATH  = "/validate"
+	ADMISSION_MUTATE_PATH    = "/mutate"
+	ADMISSION_MAX_BODY_BYTES = 1 << 20
+)
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// certificateReloader serves the certificate files and reloads them when they change,
+// as Secret volumes are updated in place on certificate renewal.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	modTime     time.Time
+	certificate *tls.Certificate
+}
+
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	info, err := os.Stat(c.certFile)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err == nil && c.certificate != nil && info.ModTime().Equal(c.modTime) {
+		return c.certificate, nil
+	}
+
+	certificate, loadErr := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if loadErr != nil {
+		// A renewal might be half-written, keep serving the previous certificate until it is complete.
+		if c.certificate != nil {
+			return c.certificate, nil
+		}
+		return nil, loadErr
+	}
+	c.certificate = &certificate
+	if err == nil {
+		c.modTime = info.ModTime()
+	}
+	return c.certificate, nil
+}
+
+func (n *NsInformer) serveAdmission(ctx context.Context) {
+	reloader := &certificateReloader{certFile: n.config().Admission.CertFile, keyFile: n.config().Admission.KeyFile}
+	if _, err := reloader.GetCertificate(nil); err != nil {
+		n.logger.Error("Could not load admission webhook certificate", "ERROR:", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(ADMISSION_VALIDATE_PATH, n.handleAdmissionReview(n.validateNamespace))
+	mux.HandleFunc(ADMISSION_MUTATE_PATH, n.handleAdmissionReview(n.mutateNamespace))
+
+	server := &http.Server{
+		Addr:              n.config().Admission.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	n.logger.Info("Serving admission webhooks", "Address", server.Addr, "Paths", []string{ADMISSION_VALIDATE_PATH, ADMISSION_MUTATE_PATH})
+	if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		n.logger.Error("Admission webhooks stopped", "ERROR:", err)
+	}
+}
+
+// handleAdmissionReview decodes the AdmissionReview sent by the API server and answers it with the review response.
+func (n *NsInformer) handleAdmissionReview(
+	review func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		admissionReview := admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, ADMISSION_MAX_BODY_BYTES)).Decode(&admissionReview); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if admissionReview.Request == nil {
+			http.Error(w, "AdmissionReview request is missing", http.StatusBadRequest)
+			return
+		}
+
+		response := &admissionv1.AdmissionResponse{Allowed: true}
+		if admissionReview.Request.Kind.Kind == "Namespace" {
+			response = review(admissionReview.Request)
+		}
+		response.UID = admissionReview.Request.UID
+		admissionReview.Request = nil
+		admissionReview.Response = response
+
+		writeJSON(w, http.StatusOK, admissionReview)
+	}
+}
+
+// validateNamespace denies changes of selected namespaces which let them escape the cleanup:
+// malformed or too distant deletion timestamps and protection set by users who are not allowed to
+// or without a reason.
+func (n *NsInformer) validateNamespace(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	if n.isAdmissionExempt(request.UserInfo) {
+		return allowed
+	}
+
+	ns, oldNs, err := decodeAdmissionNamespaces(request)
+	if err != nil {
+		return admissionError(err)
+	}
+	if ns == nil || !(n.isSelected(ns) || (oldNs != nil && n.isSelected(oldNs))) {
+		return allowed
+	}
+
+	errs := make([]error, 0)
+	annotationKey := n.config().AnnotationKey
+	if deleteAfter, ok := ns.Annotations[annotationKey]; ok && isAnnotationChanged(ns, oldNs, annotationKey) {
+		errs = append(errs, n.validateDeleteAfter(ns, deleteAfter))
+	}
+
+	errs = append(errs, n.validateProtection(request.UserInfo, ns, oldNs))
+
+	if err := errors.Join(errs...); err != nil {
+		n.logger.Warn("Denied namespace change", "namespace", ns.Name, "User", request.UserInfo.Username, "ERROR:", err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: err.Error(),
+			},
+		}
+	}
+	return allowed
+}
+
+// validateProtection checks the protection annotations which are set or changed: only allowed users
+// may protect a namespace, the reason is required and the expiry must be a timestamp.
+func (n *NsInformer) validateProtection(user authenticationv1.UserInfo, ns *corev1.Namespace, oldNs *corev1.Namespace) error {
+	errs := make([]error, 0)
+	isProtecting := false
+	for _, key := range []string{n.config().NsPreserveAnnotation, n.config().NsProtectedUntilAnnotation} {
+		if _, ok := ns.Annotations[key]; ok && isAnnotationChanged(ns, oldNs, key) {
+			isProtecting = true
+			if !n.isProtectAllowed(user) {
+				errs = append(errs, fmt.Errorf("%s is not allowed to set the %s annotation", user.Username, key))
+			}
+		}
+	}
+
+	protection := n.protectionOf(ns)
+	if isProtecting || (protection.IsSet && isAnnotationChanged(ns, oldNs, n.config().NsProtectionReasonAnnotation)) {
+		if protection.Reason == "" {
+			errs = append(errs, fmt.Errorf("protection requires a reason in the %s annotation", n.config().NsProtectionReasonAnnotation))
+		}
+		errs = append(errs, protection.UntilErr)
+	}
+	return errors.Join(errs...)
+}
+
+func (n *NsInformer) validateDeleteAfter(ns *corev1.Namespace, deleteAfter string) error {
+	annotationKey := n.config().AnnotationKey
+	timestamp, err := time.Parse(time.RFC3339, deleteAfter)
+	if err != nil {
+		return fmt.Errorf("%s should be an RFC3339 timestamp like 2006-01-02T15:04:05Z, got %q", annotationKey, deleteAfter)
+	}
+
+	maxTTL := n.admissionMaxTTL(ns)
+	if limit := time.Now().UTC().Add(maxTTL); timestamp.After(limit) {
+		return fmt.Errorf(
+			"%s should not be later than %s, which is %s from now, got %q",
+			annotationKey,
+			limit.Format(time.RFC3339),
+			utils.FormatDuration(maxTTL),
+			deleteAfter,
+		)
+	}
+	return nil
+}
+
+// mutateNamespace stamps the deletion timestamp on namespaces selected at creation, so it is counted from
+// the creation even if the reaper is not running, and normalizes timestamps to UTC, which the reaper reads.
+func (n *NsInformer) mutateNamespace(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+	ns, _, err := decodeAdmissionNamespaces(request)
+	if err != nil {
+		return admissionError(err)
+	}
+	if ns == nil || !n.isSelected(ns) {
+		return response
+	}
+
+	annotationKey := n.config().AnnotationKey
+	deleteAfter, ok := ns.Annotations[annotationKey]
+	switch {
+	case !ok && request.Operation == admissionv1.Create:
+		ttl := n.policyFor(ns).Retention
+		if maxTTL := n.admissionMaxTTL(ns); maxTTL < ttl {
+			ttl = maxTTL
+		}
+		deleteAfter = time.Now().UTC().Add(ttl).Format(time.RFC3339)
+	case ok:
+		timestamp, err := time.Parse(time.RFC3339, deleteAfter)
+		if err != nil || timestamp.UTC().Format(time.RFC3339) == deleteAfter {
+			return response
+		}
+		deleteAfter = timestamp.UTC().Format(time.RFC3339)
+	default:
+		return response
+	}
+
+	operation := jsonPatchOperation{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(annotationKey), Value: deleteAfter}
+	if ns.Annotations == nil {
+		operation = jsonPatchOperation{Op: "add", Path: "/metadata/annotations", Value: map[string]string{annotationKey: deleteAfter}}
+	}
+	patch, err := json.Marshal([]jsonPatchOperation{operation})
+	if err != nil {
+		return admissionError(err)
+	}
+
+	patchType := admissionv1.PatchTypeJSONPatch
+	response.Patch = patch
+	response.PatchType = &patchType
+	return response
+}
+
+// admissionMaxTTL is how far from now the deletion timestamp of the namespace might be set.
+func (n *NsInformer) admissionMaxTTL(ns *corev1.Namespace) time.Duration {
+	if maxTTL := n.config().Admission.MaxTTL; maxTTL > 0 {
+		return maxTTL
+	}
+	return n.policyFor(ns).Retention
+}
+
+// isAdmissionExempt tells whether the request is made by the reaper itself or another trusted user.
+func (n *NsInformer) isAdmissionExempt(user authenticationv1.UserInfo) bool {
+	return utils.IsContains(n.config().Admission.ExemptUsers, user.Username)
+}
+
+func (n *NsInformer) isProtectAllowed(user authenticationv1.UserInfo) bool {
+	if utils.IsContains(n.config().Admission.ProtectAllowedUsers, user.Username) {
+		return true
+	}
+	for _, group := range user.Groups {
+		if utils.IsContains(n.config().Admission.ProtectAllowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeAdmissionNamespaces returns the namespace of the request and the namespace before the change,
+// which is nil on creation. Both are nil on deletion.
+func decodeAdmissionNamespaces(request *admissionv1.AdmissionRequest) (*corev1.Namespace, *corev1.Namespace, error) {
+	if len(request.Object.Raw) == 0 {
+		return nil, nil, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := json.Unmarshal(request.Object.Raw, ns); err != nil {
+		return nil, nil, fmt.Errorf("Could not decode namespace: %w", err)
+	}
+	if len(request.OldObject.Raw) == 0 {
+		return ns, nil, nil
+	}
+	oldNs := &corev1.Namespace{}
+	if err := json.Unmarshal(request.OldObject.Raw, oldNs); err != nil {
+		return nil, nil, fmt.Errorf("Could not decode namespace: %w", err)
+	}
+	return ns, oldNs, nil
+}
+
+func isAnnotationChanged(ns *corev1.Namespace, oldNs *corev1.Namespace, key string) bool {
+	if oldNs == nil {
+		return true
+	}
+	oldValue, wasSet := oldNs.Annotations[key]
+	value, isSet := ns.Annotations[key]
+	return wasSet != isSet || oldValue != value
+}
+
+func admissionError(err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: err.Error(),
+		},
+	}
+}
+
+// escapeJSONPointer escapes a map key for a JSON patch path, annotation keys often contain "/".
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,376 @@
+package namespaces_informer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const admissionReviewTemplate = `{
+  "apiVersion": "admission.k8s.io/v1",
+  "kind": "AdmissionReview",
+  "request": {
+    "uid": "705ab4f5-6393-11e8-b7cc-42010a800002",
+    "kind": {"group": "", "version": "v1", "kind": "%s"},
+    "resource": {"group": "", "version": "v1", "resource": "namespaces"},
+    "operation": "%s",
+    "userInfo": {"username": "%s", "groups": ["system:authenticated", "%s"]},
+    "object": %s,
+    "oldObject": %s
+  }
+}`
+
+type admissionRequest struct {
+	kind      string
+	operation string
+	user      string
+	group     string
+	object    string
+	oldObject string
+}
+
+// namespaceJSON is a namespace object as the API server sends it, with the annotations as a JSON object.
+func namespaceJSON(name string, annotations string) string {
+	if annotations == "" {
+		return fmt.Sprintf(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": %q}}`, name)
+	}
+	return fmt.Sprintf(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": %q, "annotations": %s}}`, name, annotations)
+}
+
+// sendAdmissionReview posts the AdmissionReview to the handler and returns the response of the review.
+func sendAdmissionReview(
+	t *testing.T,
+	handler http.HandlerFunc,
+	request admissionRequest,
+) *admissionv1.AdmissionResponse {
+	t.Helper()
+	if request.kind == "" {
+		request.kind = "Namespace"
+	}
+	if request.oldObject == "" {
+		request.oldObject = "null"
+	}
+	body := fmt.Sprintf(
+		admissionReviewTemplate,
+		request.kind, request.operation, request.user, request.group, request.object, request.oldObject,
+	)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, ADMISSION_VALIDATE_PATH, strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(recorder.Body).Decode(&review); err != nil {
+		t.Fatal(err)
+	}
+	if review.Response == nil || review.Response.UID != "705ab4f5-6393-11e8-b7cc-42010a800002" {
+		t.Fatalf("expected the response to the request, got %+v", review.Response)
+	}
+	return review.Response
+}
+
+func newAdmissionTestInformer(t *testing.T, maxTTL time.Duration) *NsInformer {
+	config := newTestConfig()
+	config.Admission.MaxTTL = maxTTL
+	config.Admission.ProtectAllowedUsers = []string{"alice"}
+	config.Admission.ProtectAllowedGroups = []string{"sre"}
+	config.Admission.ExemptUsers = []string{"system:serviceaccount:review-reaper:review-reaper"}
+	n, _ := newTestInformer(t, config)
+	return n
+}
+
+func TestValidateNamespace(t *testing.T) {
+	inTwoWeeks := time.Now().UTC().Add(14 * 24 * time.Hour).Format(time.RFC3339)
+	inOneDay := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name    string
+		request admissionRequest
+		err     string
+	}{
+		{
+			name: "protection with a reason",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "alice",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected": "true", "review-reaper-protected-reason": "demo"}`),
+				oldObject: namespaceJSON("review-login", ""),
+			},
+		},
+		{
+			name: "protection without a reason",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "alice",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected": "true"}`),
+				oldObject: namespaceJSON("review-login", ""),
+			},
+			err: "protection requires a reason in the review-reaper-protected-reason annotation",
+		},
+		{
+			name: "protection by a disallowed user",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "bob",
+				group:     "developers",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected": "true", "review-reaper-protected-reason": "demo"}`),
+				oldObject: namespaceJSON("review-login", ""),
+			},
+			err: "bob is not allowed to set the review-reaper-protected annotation",
+		},
+		{
+			name: "protection by an allowed group",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "bob",
+				group:     "sre",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected-until": "2030-01-01T00:00:00Z", "review-reaper-protected-reason": "demo"}`),
+				oldObject: namespaceJSON("review-login", ""),
+			},
+		},
+		{
+			name: "invalid protected-until",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "alice",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected-until": "tomorrow", "review-reaper-protected-reason": "demo"}`),
+				oldObject: namespaceJSON("review-login", ""),
+			},
+			err: `invalid review-reaper-protected-until "tomorrow", expected an RFC3339 timestamp`,
+		},
+		{
+			name: "reason removed from a protected namespace",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "bob",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected": "true"}`),
+				oldObject: namespaceJSON("review-login", `{"review-reaper-protected": "true", "review-reaper-protected-reason": "demo"}`),
+			},
+			err: "protection requires a reason",
+		},
+		{
+			name: "deletion timestamp within MaxTTL",
+			request: admissionRequest{
+				operation: "CREATE",
+				user:      "bob",
+				object:    namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q}`, inOneDay)),
+			},
+		},
+		{
+			name: "deletion timestamp over MaxTTL",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "bob",
+				object:    namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q}`, inTwoWeeks)),
+				oldObject: namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q}`, inOneDay)),
+			},
+			err: "delete_after should not be later than",
+		},
+		{
+			name: "unchanged deletion timestamp over MaxTTL",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "bob",
+				object:    namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q, "owner": "bob"}`, inTwoWeeks)),
+				oldObject: namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q}`, inTwoWeeks)),
+			},
+		},
+		{
+			name: "malformed deletion timestamp",
+			request: admissionRequest{
+				operation: "CREATE",
+				user:      "bob",
+				object:    namespaceJSON("review-login", `{"delete_after": "next week"}`),
+			},
+			err: `delete_after should be an RFC3339 timestamp like 2006-01-02T15:04:05Z, got "next week"`,
+		},
+		{
+			name: "exempt user",
+			request: admissionRequest{
+				operation: "UPDATE",
+				user:      "system:serviceaccount:review-reaper:review-reaper",
+				object:    namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q, "review-reaper-protected": "true"}`, inTwoWeeks)),
+				oldObject: namespaceJSON("review-login", ""),
+			},
+		},
+		{
+			name: "namespace not selected",
+			request: admissionRequest{
+				operation: "CREATE",
+				user:      "bob",
+				object:    namespaceJSON("production", `{"review-reaper-protected": "true", "delete_after": "never"}`),
+			},
+		},
+		{
+			name: "not a namespace",
+			request: admissionRequest{
+				kind:      "ConfigMap",
+				operation: "CREATE",
+				user:      "bob",
+				object:    namespaceJSON("review-login", `{"review-reaper-protected": "true"}`),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newAdmissionTestInformer(t, 7*24*time.Hour)
+			response := sendAdmissionReview(t, n.handleAdmissionReview(n.validateNamespace), tt.request)
+
+			if tt.err == "" {
+				if !response.Allowed {
+					t.Errorf("expected the change to be allowed, got %+v", response.Result)
+				}
+				return
+			}
+			if response.Allowed || response.Result == nil || response.Result.Code != http.StatusForbidden {
+				t.Fatalf("expected the change to be denied, got %+v", response)
+			}
+			if !strings.Contains(response.Result.Message, tt.err) {
+				t.Errorf("expected message %q, got %q", tt.err, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestMutateNamespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxTTL  time.Duration
+		request admissionRequest
+		// patch is the expected JSON patch, {{ttl}} in it is replaced by now plus ttl.
+		patch string
+		ttl   time.Duration
+	}{
+		{
+			name: "created without a deletion timestamp",
+			request: admissionRequest{
+				operation: "CREATE",
+				object:    namespaceJSON("review-login", `{"owner": "bob"}`),
+			},
+			patch: `[{"op":"add","path":"/metadata/annotations/delete_after","value":"{{ttl}}"}]`,
+			ttl:   7 * 24 * time.Hour,
+		},
+		{
+			name: "created without annotations",
+			request: admissionRequest{
+				operation: "CREATE",
+				object:    namespaceJSON("review-login", ""),
+			},
+			patch: `[{"op":"add","path":"/metadata/annotations","value":{"delete_after":"{{ttl}}"}}]`,
+			ttl:   7 * 24 * time.Hour,
+		},
+		{
+			name:   "created with MaxTTL shorter than the retention",
+			maxTTL: 2 * 24 * time.Hour,
+			request: admissionRequest{
+				operation: "CREATE",
+				object:    namespaceJSON("review-login", `{}`),
+			},
+			patch: `[{"op":"add","path":"/metadata/annotations/delete_after","value":"{{ttl}}"}]`,
+			ttl:   2 * 24 * time.Hour,
+		},
+		{
+			name: "timestamp with an offset",
+			request: admissionRequest{
+				operation: "UPDATE",
+				object:    namespaceJSON("review-login", `{"delete_after": "2030-01-01T03:00:00+03:00"}`),
+				oldObject: namespaceJSON("review-login", `{"delete_after": "2030-01-01T00:00:00Z"}`),
+			},
+			patch: `[{"op":"add","path":"/metadata/annotations/delete_after","value":"2030-01-01T00:00:00Z"}]`,
+		},
+		{
+			name: "timestamp in UTC",
+			request: admissionRequest{
+				operation: "CREATE",
+				object:    namespaceJSON("review-login", `{"delete_after": "2030-01-01T00:00:00Z"}`),
+			},
+		},
+		{
+			name: "malformed timestamp is left to validation",
+			request: admissionRequest{
+				operation: "CREATE",
+				object:    namespaceJSON("review-login", `{"delete_after": "next week"}`),
+			},
+		},
+		{
+			name: "updated without a deletion timestamp",
+			request: admissionRequest{
+				operation: "UPDATE",
+				object:    namespaceJSON("review-login", ""),
+				oldObject: namespaceJSON("review-login", `{"delete_after": "2030-01-01T00:00:00Z"}`),
+			},
+		},
+		{
+			name: "namespace not selected",
+			request: admissionRequest{
+				operation: "CREATE",
+				object:    namespaceJSON("production", ""),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newAdmissionTestInformer(t, tt.maxTTL)
+			tt.request.user = "bob"
+			before := time.Now().UTC()
+			response := sendAdmissionReview(t, n.handleAdmissionReview(n.mutateNamespace), tt.request)
+			after := time.Now().UTC()
+
+			if !response.Allowed {
+				t.Fatalf("expected the change to be allowed, got %+v", response.Result)
+			}
+			if tt.patch == "" {
+				if response.Patch != nil {
+					t.Errorf("expected no patch, got %s", response.Patch)
+				}
+				return
+			}
+			if response.PatchType == nil || *response.PatchType != admissionv1.PatchTypeJSONPatch {
+				t.Errorf("expected a JSON patch, got %v", response.PatchType)
+			}
+
+			// The deletion timestamp is stamped between the request and the response, in seconds.
+			patch := string(response.Patch)
+			for _, now := range []time.Time{before, after} {
+				expected := strings.Replace(tt.patch, "{{ttl}}", now.Add(tt.ttl).Format(time.RFC3339), 1)
+				if patch == expected {
+					return
+				}
+			}
+			t.Errorf("expected patch %s, got %s", tt.patch, patch)
+		})
+	}
+}
+
+// TestDeleteAfterOffsetRoundTrip reads back a deletion timestamp with an offset which the validating
+// webhook accepted and the mutating one did not normalise, like when it is skipped.
+func TestDeleteAfterOffsetRoundTrip(t *testing.T) {
+	n := newAdmissionTestInformer(t, 0)
+	deleteAfter := time.Now().In(time.FixedZone("CEST", 2*60*60)).Add(time.Hour).Truncate(time.Second)
+
+	response := sendAdmissionReview(t, n.handleAdmissionReview(n.validateNamespace), admissionRequest{
+		operation: "CREATE",
+		user:      "bob",
+		object:    namespaceJSON("review-login", fmt.Sprintf(`{"delete_after": %q}`, deleteAfter.Format(time.RFC3339))),
+	})
+	if !response.Allowed {
+		t.Fatalf("expected the offset timestamp to be allowed, got %+v", response.Result)
+	}
+
+	ns := newTestNamespace("review-login", map[string]string{"delete_after": deleteAfter.Format(time.RFC3339)})
+	timestamp, err := n.getNsDeletionTimespamp(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !timestamp.Equal(deleteAfter) || timestamp.Location() != time.UTC {
+		t.Errorf("expected %s in UTC, got %s", deleteAfter.UTC(), timestamp)
+	}
+}
@@ -0,0 +1,168 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/backup"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const BACKUP_TIMESTAMP = "20060102T150405Z"
+
+// Resources which are not worth exporting: they are either recreated by controllers or are pure noise.
+var skippedBackupResources = []string{"events", "pods", "replicasets", "endpoints", "endpointslices"}
+
+func (n *NsInformer) setupBackup() error {
+	cfg := n.config().Backup
+
+	if err := n.setupDynamicClient(); err != nil {
+		return err
+	}
+
+	var err error
+	if cfg.S3.Endpoint != "" {
+		n.backupStore, err = backup.NewS3Store(
+			cfg.S3.Endpoint,
+			cfg.S3.Bucket,
+			cfg.S3.Prefix,
+			cfg.S3.Region,
+			cfg.S3.AccessKey,
+			cfg.S3.SecretKey,
+		)
+		return err
+	}
+
+	n.backupStore, err = backup.NewDirStore(cfg.Directory)
+	return err
+}
+
+// backupNamespace exports all namespaced objects of the namespace as YAML manifests into
+// a tarball and prunes archives which are older than the configured retention.
+func (n *NsInformer) backupNamespace(ctx context.Context, ns *corev1.Namespace) error {
+	resources, err := n.listNamespacedResources(func(resource metav1.APIResource) bool {
+		return isBackupListable(resource, n.config().Backup.IncludeSecrets)
+	})
+	if err != nil {
+		return err
+	}
+
+	archive := backup.NewArchive()
+	objectsCount := 0
+
+	for _, gvr := range resources {
+		group := gvr.Group
+		if group == "" {
+			group = "core"
+		}
+		list, err := n.dynamicClient.Resource(gvr).Namespace(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			n.logger.Warn("Could not list resources for backup", "namespace", ns.Name, "Resource", gvr.String(), "ERROR:", err)
+			continue
+		}
+
+		for _, item := range list.Items {
+			item.SetManagedFields(nil)
+			manifest, err := yaml.Marshal(item.Object)
+			if err != nil {
+				return err
+			}
+			fileName := path.Join(ns.Name, group, gvr.Resource, item.GetName()+".yaml")
+			if err := archive.AddFile(fileName, manifest); err != nil {
+				return err
+			}
+			objectsCount++
+		}
+	}
+
+	data, err := archive.Bytes()
+	if err != nil {
+		return err
+	}
+
+	archiveName := fmt.Sprintf("%s-%s.tar.gz", ns.Name, time.Now().UTC().Format(BACKUP_TIMESTAMP))
+	if err := n.backupStore.Put(ctx, archiveName, data); err != nil {
+		return err
+	}
+	n.logger.Info("Namespace backed up", "namespace", ns.Name, "Archive", archiveName, "Objects", objectsCount)
+
+	maxAge := n.config().Backup.Retention
+	pruned, err := backup.Prune(ctx, n.backupStore, maxAge)
+	if err != nil {
+		n.logger.Warn("Could not prune old backups", "ERROR:", err)
+	} else if len(pruned) > 0 {
+		n.logger.Info("Pruned old backups", "Archives", pruned)
+	}
+
+	return nil
+}
+
+func (n *NsInformer) setupDynamicClient() error {
+	if n.dynamicClient != nil {
+		return nil
+	}
+	dynamicClient, err := dynamic.NewForConfig(n.restConfig)
+	if err != nil {
+		return err
+	}
+	n.dynamicClient = dynamicClient
+	return nil
+}
+
+// listNamespacedResources discovers namespaced resources in their preferred versions,
+// keeping only those accepted by the filter.
+func (n *NsInformer) listNamespacedResources(
+	filter func(metav1.APIResource) bool,
+) ([]schema.GroupVersionResource, error) {
+	resourceLists, err := n.client.Discovery().ServerPreferredNamespacedResources()
+	if err != nil && len(resourceLists) == 0 {
+		return nil, err
+	}
+	if err != nil {
+		// Partial discovery failures (like an unavailable aggregated API) should not block backups.
+		n.logger.Warn("Partial API discovery failure", "ERROR:", err)
+	}
+
+	resources := make([]schema.GroupVersionResource, 0)
+	for _, resourceList := range resourceLists {
+		groupVersion, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			if !filter(resource) {
+				continue
+			}
+			resources = append(resources, groupVersion.WithResource(resource.Name))
+		}
+	}
+	return resources, nil
+}
+
+func isBackupListable(resource metav1.APIResource, includeSecrets bool) bool {
+	if !isListable(resource) {
+		return false
+	}
+	if !includeSecrets && resource.Name == "secrets" {
+		return false
+	}
+	if utils.IsContains(skippedBackupResources, resource.Name) {
+		return false
+	}
+	return true
+}
+
+func isListable(resource metav1.APIResource) bool {
+	if strings.Contains(resource.Name, "/") {
+		return false
+	}
+	return utils.IsContains([]string(resource.Verbs), "list")
+}
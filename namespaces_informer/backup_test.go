@@ -0,0 +1,174 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/backup"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// discoveryClientset serves the resources of the fake clientset as preferred ones,
+// the fake discovery does not return any.
+type discoveryClientset struct {
+	*fake.Clientset
+}
+
+func (c *discoveryClientset) Discovery() discovery.DiscoveryInterface {
+	return &preferredDiscovery{c.Clientset.Discovery().(*fakediscovery.FakeDiscovery)}
+}
+
+type preferredDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d *preferredDiscovery) ServerPreferredNamespacedResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+func newTestObject(apiVersion string, kind string, namespace string, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": name},
+	}}
+}
+
+// readTestArchive returns the file names of the only archive in the directory.
+func readTestArchive(t *testing.T, dir string) []string {
+	t.Helper()
+	archives, err := filepath.Glob(filepath.Join(dir, "review-login-*.tar.gz"))
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("expected a single archive, got %v %v", archives, err)
+	}
+	data, err := os.ReadFile(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzReader)
+
+	names := make([]string, 0)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestBackupNamespace(t *testing.T) {
+	tests := []struct {
+		name           string
+		includeSecrets bool
+		files          []string
+	}{
+		{
+			name:  "without secrets",
+			files: []string{"review-login/apps/deployments/app.yaml", "review-login/core/configmaps/settings.yaml"},
+		},
+		{
+			name:           "with secrets",
+			includeSecrets: true,
+			files: []string{
+				"review-login/apps/deployments/app.yaml",
+				"review-login/core/configmaps/settings.yaml",
+				"review-login/core/secrets/token.yaml",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := newTestConfig()
+			config.Backup.Enabled = true
+			config.Backup.IncludeSecrets = tt.includeSecrets
+			config.Backup.Retention = 24 * time.Hour
+			ns := newTestNamespace("review-login", nil)
+			n, client := newTestInformer(t, config, ns)
+
+			listVerbs := metav1.Verbs{"get", "list", "delete"}
+			client.Resources = []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "configmaps", Namespaced: true, Verbs: listVerbs},
+						{Name: "secrets", Namespaced: true, Verbs: listVerbs},
+						{Name: "pods", Namespaced: true, Verbs: listVerbs},
+						{Name: "pods/log", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+					},
+				},
+				{
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true, Verbs: listVerbs}},
+				},
+			}
+			n.client = &discoveryClientset{client}
+			n.dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+				runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					{Version: "v1", Resource: "configmaps"}:                 "ConfigMapList",
+					{Version: "v1", Resource: "secrets"}:                    "SecretList",
+					{Version: "v1", Resource: "pods"}:                       "PodList",
+					{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+				},
+				newTestObject("v1", "ConfigMap", "review-login", "settings"),
+				newTestObject("v1", "Secret", "review-login", "token"),
+				newTestObject("v1", "Pod", "review-login", "app-5d9f"),
+				newTestObject("apps/v1", "Deployment", "review-login", "app"),
+				newTestObject("v1", "ConfigMap", "review-other", "settings"),
+			)
+			store, err := backup.NewDirStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n.backupStore = store
+
+			// An archive older than the retention is pruned after the backup.
+			stale := filepath.Join(dir, "review-old-20200101T000000Z.tar.gz")
+			if err := os.WriteFile(stale, []byte{}, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			staleTime := time.Now().Add(-48 * time.Hour)
+			if err := os.Chtimes(stale, staleTime, staleTime); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := n.backupNamespace(context.Background(), ns); err != nil {
+				t.Fatal(err)
+			}
+
+			if files := readTestArchive(t, dir); strings.Join(files, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("expected files %v, got %v", tt.files, files)
+			}
+			if _, err := os.Stat(stale); !os.IsNotExist(err) {
+				t.Errorf("expected the stale archive to be pruned, got %v", err)
+			}
+		})
+	}
+}
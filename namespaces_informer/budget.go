@@ -0,0 +1,217 @@
+package namespaces_informer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	BUDGET_STRATEGY_LARGEST = "largest"
+	BUDGET_STRATEGY_OLDEST  = "oldest"
+	BUDGET_EXPIRED_BY       = "budget"
+	BUDGET_EVENT_REASON     = "ReviewBudgetExceeded"
+)
+
+// nsUsage is the sum of resource requests of running pods in a namespace.
+type nsUsage struct {
+	ns     *corev1.Namespace
+	cpu    resource.Quantity
+	memory resource.Quantity
+}
+
+// BudgetTicker periodically sums pod resource requests across live watched namespaces and,
+// when the budget is exceeded, evicts namespaces picked by the configured strategy
+// until the rest fits into the budget.
+func (n *NsInformer) BudgetTicker(ctx context.Context) {
+	ticker := time.NewTicker(n.config().Budget.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Finishig budget ticker...")
+			return
+		case <-ticker.C:
+			n.enforceBudget(ctx)
+		}
+	}
+}
+
+func (n *NsInformer) enforceBudget(ctx context.Context) {
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		n.logger.Error("Could not list watched namespaces to check budget", err)
+		return
+	}
+
+	usages := make([]nsUsage, 0, len(watchedNamespaces))
+	totalCPU := resource.Quantity{}
+	totalMemory := resource.Quantity{}
+	for _, ns := range watchedNamespaces {
+		if !n.isLive(ns) {
+			continue
+		}
+		usage, err := n.namespaceUsage(ns)
+		if err != nil {
+			n.logger.Error("Could not sum resource requests", "namespace", ns.Name, "ERROR:", err)
+			continue
+		}
+		totalCPU.Add(usage.cpu)
+		totalMemory.Add(usage.memory)
+		usages = append(usages, usage)
+	}
+
+	n.logger.Debug("Review namespaces resource requests", "CPU", totalCPU.String(), "Memory", totalMemory.String())
+	if !n.isOverBudget(totalCPU, totalMemory) {
+		return
+	}
+
+	n.sortForBudgetEviction(usages)
+
+	reclaimedCPU := resource.Quantity{}
+	reclaimedMemory := resource.Quantity{}
+	evicted := make([]string, 0)
+	for _, usage := range usages {
+		if !n.isOverBudget(totalCPU, totalMemory) {
+			break
+		}
+		reason := fmt.Sprintf(
+			"review namespaces request %s CPU and %s memory, budget is %s CPU and %s memory",
+			totalCPU.String(),
+			totalMemory.String(),
+			budgetOrUnlimited(n.config().Budget.CPU),
+			budgetOrUnlimited(n.config().Budget.Memory),
+		)
+		// Dry run only picks the namespaces, they are logged together below.
+		if !n.config().DryRun && !n.evictNamespace(ctx, usage.ns, BUDGET_EXPIRED_BY, BUDGET_EVENT_REASON, reason) {
+			continue
+		}
+
+		totalCPU.Sub(usage.cpu)
+		totalMemory.Sub(usage.memory)
+		reclaimedCPU.Add(usage.cpu)
+		reclaimedMemory.Add(usage.memory)
+		evicted = append(evicted, usage.ns.Name)
+	}
+
+	if n.config().DryRun {
+		n.logger.Info(
+			"[DRY-RUN] want to evict to meet the resource budget",
+			"Namespaces",
+			strings.Join(evicted, ", "),
+			"CPU",
+			reclaimedCPU.String(),
+			"Memory",
+			reclaimedMemory.String(),
+		)
+		return
+	}
+	if len(evicted) == 0 {
+		return
+	}
+
+	n.logger.Warn(
+		"Resource budget exceeded, capacity will be reclaimed in the next maintenance window",
+		"Namespaces",
+		strings.Join(evicted, ", "),
+		"ReclaimedCPU",
+		reclaimedCPU.String(),
+		"ReclaimedMemory",
+		reclaimedMemory.String(),
+	)
+}
+
+// namespaceUsage sums effective requests of the namespace pods which are not finished,
+// the same way the scheduler does: the larger of the containers sum and the largest init container,
+// plus the pod overhead.
+func (n *NsInformer) namespaceUsage(ns *corev1.Namespace) (nsUsage, error) {
+	usage := nsUsage{ns: ns}
+	pods, err := n.podLister.Pods(ns.Name).List(labels.Everything())
+	if err != nil {
+		return usage, err
+	}
+
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			containers := resource.Quantity{}
+			for _, container := range pod.Spec.Containers {
+				containers.Add(container.Resources.Requests[name])
+			}
+			for _, container := range pod.Spec.InitContainers {
+				if request := container.Resources.Requests[name]; request.Cmp(containers) > 0 {
+					containers = request.DeepCopy()
+				}
+			}
+			containers.Add(pod.Spec.Overhead[name])
+
+			if name == corev1.ResourceCPU {
+				usage.cpu.Add(containers)
+			} else {
+				usage.memory.Add(containers)
+			}
+		}
+	}
+	return usage, nil
+}
+
+func (n *NsInformer) isOverBudget(cpu resource.Quantity, memory resource.Quantity) bool {
+	if n.config().Budget.CPU != "" && cpu.Cmp(resource.MustParse(n.config().Budget.CPU)) > 0 {
+		return true
+	}
+	if n.config().Budget.Memory != "" && memory.Cmp(resource.MustParse(n.config().Budget.Memory)) > 0 {
+		return true
+	}
+	return false
+}
+
+// sortForBudgetEviction orders namespaces to evict first: the largest share of the budget
+// or the earliest created.
+func (n *NsInformer) sortForBudgetEviction(usages []nsUsage) {
+	if n.config().Budget.Strategy == BUDGET_STRATEGY_OLDEST {
+		sort.SliceStable(usages, func(i, j int) bool {
+			return n.getNsCreationTimestamp(usages[i].ns).Before(n.getNsCreationTimestamp(usages[j].ns))
+		})
+		return
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		return n.budgetShare(usages[i]) > n.budgetShare(usages[j])
+	})
+}
+
+// budgetShare is the largest fraction of the configured budgets the namespace requests.
+func (n *NsInformer) budgetShare(usage nsUsage) float64 {
+	share := 0.0
+	if n.config().Budget.CPU != "" {
+		budget := resource.MustParse(n.config().Budget.CPU)
+		if budget.MilliValue() > 0 {
+			share = float64(usage.cpu.MilliValue()) / float64(budget.MilliValue())
+		}
+	}
+	if n.config().Budget.Memory != "" {
+		budget := resource.MustParse(n.config().Budget.Memory)
+		if budget.Value() > 0 {
+			if memoryShare := float64(usage.memory.Value()) / float64(budget.Value()); memoryShare > share {
+				share = memoryShare
+			}
+		}
+	}
+	return share
+}
+
+func budgetOrUnlimited(quantity string) string {
+	if quantity == "" {
+		return "unlimited"
+	}
+	return quantity
+}
@@ -0,0 +1,109 @@
+package namespaces_informer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	listers "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// setTestPods serves the pods requesting the CPU by namespace from the pod lister.
+func setTestPods(t *testing.T, n *NsInformer, cpuRequests map[string]string) {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for namespace, cpu := range cpuRequests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:      "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}},
+			}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n.podLister = listers.NewPodLister(indexer)
+}
+
+func TestEnforceBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		dryRun    bool
+		forbidden string
+		evicted   []string
+		logs      []string
+	}{
+		{
+			name:    "largest first until the budget fits",
+			evicted: []string{"review-large"},
+			logs:    []string{"Resource budget exceeded", "Namespaces=review-large ReclaimedCPU=1500m"},
+		},
+		{
+			name:      "failed eviction is not reclaimed",
+			forbidden: "review-large",
+			evicted:   []string{"review-medium"},
+			logs:      []string{"Namespaces=review-medium ReclaimedCPU=1"},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			logs:   []string{"[DRY-RUN] want to evict to meet the resource budget: Namespaces=review-large CPU=1500m"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.DryRun = tt.dryRun
+			config.Budget.Enabled = true
+			config.Budget.CPU = "2"
+			config.Budget.Strategy = BUDGET_STRATEGY_LARGEST
+			n, client := newTestInformer(
+				t,
+				config,
+				newTestNamespace("review-large", nil),
+				newTestNamespace("review-medium", nil),
+				newTestNamespace("review-small", nil),
+			)
+			setTestPods(t, n, map[string]string{"review-large": "1500m", "review-medium": "1", "review-small": "500m"})
+			client.PrependReactor("update", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				ns := action.(k8stesting.UpdateAction).GetObject().(*corev1.Namespace)
+				return ns.Name == tt.forbidden, nil, errors.New("namespaces is forbidden")
+			})
+			var output bytes.Buffer
+			n.logger = hclog.New(&hclog.LoggerOptions{Output: &output})
+
+			n.enforceBudget(context.Background())
+
+			isEvicted := make(map[string]bool)
+			for _, name := range tt.evicted {
+				isEvicted[name] = true
+			}
+			for _, name := range []string{"review-large", "review-medium", "review-small"} {
+				_, ok := getTestNamespace(t, client, name).Annotations[config.NsExpiredByAnnotation]
+				if ok != isEvicted[name] {
+					t.Errorf("%s evicted: %v, expected %v", name, ok, isEvicted[name])
+				}
+			}
+			for _, line := range tt.logs {
+				if strings.Count(output.String(), line) != 1 {
+					t.Errorf("expected a single %q in the logs\n%s", line, output.String())
+				}
+			}
+			if tt.dryRun && strings.Contains(output.String(), "Resource budget exceeded") {
+				t.Errorf("expected no evictions to be reported in dry run\n%s", output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/policies"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	CLAIM_PHASE_INVALID = "Invalid"
+	CLAIM_PHASE_IGNORED = "Ignored"
+)
+
+// setupClaims starts the ReaperClaim informer, the same way setupPolicies does.
+func (n *NsInformer) setupClaims(ctx context.Context, isWatching bool) (cache.InformerSynced, error) {
+	n.claimClient = policies.NewClaimClient(n.dynamicClient)
+	if _, err := n.claimClient.List(ctx); err != nil {
+		return nil, fmt.Errorf("Could not list ReaperClaims, is the CRD installed? %w", err)
+	}
+
+	claimInformer := policies.NewClaimInformer(n.dynamicClient, RESYNC_TIMEOUT)
+	n.claimLister = claimInformer.Lister()
+	if isWatching {
+		claimInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { n.onClaimChange() },
+			UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+				if oldObj.(metav1.Object).GetGeneration() != newObj.(metav1.Object).GetGeneration() {
+					n.onClaimChange()
+				}
+			},
+			DeleteFunc: func(obj interface{}) { n.onClaimChange() },
+		})
+	}
+	go claimInformer.Informer().Run(ctx.Done())
+	return claimInformer.Informer().HasSynced, nil
+}
+
+func (n *NsInformer) onClaimChange() {
+	select {
+	case n.claimsChanged <- struct{}{}:
+	default:
+	}
+}
+
+// namespaceClaim returns the claim which governs the namespace: the first valid one by name.
+func (n *NsInformer) namespaceClaim(ns *corev1.Namespace) *policies.ReaperClaim {
+	if n.claimLister == nil {
+		return nil
+	}
+	for _, claim := range n.claimLister.ListInNamespace(ns.Name) {
+		if _, err := claimTTL(claim); err == nil {
+			return claim
+		}
+	}
+	return nil
+}
+
+// claimTTL returns the parsed TTL of the claim, zero if it is not set.
+func claimTTL(claim *policies.ReaperClaim) (time.Duration, error) {
+	if claim.Spec.TTL == "" {
+		return 0, nil
+	}
+	ttl, err := utils.ParseDuration(claim.Spec.TTL)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("Invalid ttl, expected a positive duration like 3d12h, got %q", claim.Spec.TTL)
+	}
+	return ttl, nil
+}
+
+// ClaimTicker reconciles claimed namespaces when claims change and writes claim statuses.
+func (n *NsInformer) ClaimTicker(ctx context.Context) {
+	ticker := time.NewTicker(POLICY_STATUS_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Finishing claim ticker...")
+			return
+		case <-n.claimsChanged:
+		case <-ticker.C:
+		}
+		n.syncClaims(ctx)
+	}
+}
+
+func (n *NsInformer) syncClaims(ctx context.Context) {
+	for _, claim := range n.claimLister.List() {
+		ns, err := n.nsLister.Get(claim.Namespace)
+		if err != nil {
+			continue
+		}
+
+		status := n.reconcileClaim(ctx, claim, ns)
+		if reflect.DeepEqual(claim.Status, status) {
+			continue
+		}
+		if err := n.claimClient.UpdateStatus(ctx, claim.Namespace, claim.Name, status); err != nil {
+			n.logger.Error("Could not update ReaperClaim status", "namespace", claim.Namespace, "Claim", claim.Name, "ERROR:", err)
+		}
+	}
+}
+
+// reconcileClaim applies the claim to its namespace and returns the claim status.
+// A new or changed claim recalculates the deletion timestamp from the namespace creation, unless
+// the namespace has already been expired by a webhook, forge sync or quota.
+func (n *NsInformer) reconcileClaim(
+	ctx context.Context,
+	claim *policies.ReaperClaim,
+	ns *corev1.Namespace,
+) policies.ReaperClaimStatus {
+	status := policies.ReaperClaimStatus{ObservedGeneration: claim.Generation}
+	if _, err := claimTTL(claim); err != nil {
+		status.Phase = CLAIM_PHASE_INVALID
+		status.Message = err.Error()
+		return status
+	}
+	// The claim might be deleted since the list, then nothing governs the namespace until the next sync.
+	governing := n.namespaceClaim(ns)
+	if governing == nil {
+		return claim.Status
+	}
+	if governing.Name != claim.Name {
+		status.Phase = CLAIM_PHASE_IGNORED
+		status.Message = fmt.Sprintf("Namespace is claimed by %s", governing.Name)
+		return status
+	}
+
+	if n.isWatched(ns) {
+		n.applyClaimMeta(ctx, claim, ns)
+
+		_, isExpired := ns.Annotations[n.config().NsExpiredByAnnotation]
+		if claim.Status.ObservedGeneration != claim.Generation && !isExpired {
+			deleteAfter := n.shiftTimeStampByRetention(ns, n.getNsCreationTimestamp(ns)).UTC().Format(time.RFC3339)
+			if err := n.annotateRetention(ctx, ns, deleteAfter); err == nil {
+				n.logger.Info("Deletion set by ReaperClaim", "NsName", ns.Name, "Claim", claim.Name, "DeletionTimestamp", deleteAfter)
+			} else {
+				// The new generation is observed once its deletion timestamp is written, so the next sync retries.
+				status.ObservedGeneration = claim.Status.ObservedGeneration
+			}
+		} else {
+			n.ensureAnnotated(ctx, ns)
+		}
+		if updated, err := n.nsLister.Get(ns.Name); err == nil {
+			ns = updated
+		}
+	}
+
+	policy := n.policyFor(ns)
+	status.Phase = claimPhase(n.nsStatusName(ns))
+	status.Policy = policy.Name
+	status.Retention = utils.FormatDuration(policy.Retention)
+	status.DeleteAfter = ns.Annotations[n.config().AnnotationKey]
+	if expiredBy := ns.Annotations[n.config().NsExpiredByAnnotation]; expiredBy != "" {
+		status.Message = fmt.Sprintf("Expired by %s", expiredBy)
+	}
+	return status
+}
+
+// applyClaimMeta copies the owner and the source ref of the claim to the namespace, where the admin API,
+// webhooks and forge sync look for them. Values set on the namespace itself are kept.
+func (n *NsInformer) applyClaimMeta(ctx context.Context, claim *policies.ReaperClaim, ns *corev1.Namespace) {
+	annotations := make(map[string]interface{})
+	setIfMissing := func(key string, value string) {
+		if key != "" && value != "" && nsMetaValue(ns, key) == "" {
+			annotations[key] = value
+		}
+	}
+	setIfMissing(n.config().AdminAPI.OwnerKey, claim.Spec.Owner)
+	setIfMissing(n.config().NsSourceRefAnnotation, claim.Spec.SourceRef)
+	setIfMissing(n.config().Webhook.BranchKey, claim.Spec.SourceRef)
+	if len(annotations) > 0 {
+		n.patchNsAnnotations(ctx, ns, annotations)
+	}
+}
+
+// claimPhase is the namespace status in the CamelCase of Kubernetes phases, like Active or Expired.
+func claimPhase(nsStatus string) string {
+	return strings.ToUpper(nsStatus[:1]) + nsStatus[1:]
+}
@@ -0,0 +1,213 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/policies"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestClaim(namespace string, name string, ttl string) *policies.ReaperClaim {
+	return &policies.ReaperClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: policies.GROUP + "/" + policies.VERSION, Kind: policies.CLAIM_KIND},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       policies.ReaperClaimSpec{TTL: ttl},
+	}
+}
+
+// newTestClaimLister returns a snapshot of the claims served by a fake dynamic client.
+func newTestClaimLister(t *testing.T, claims ...*policies.ReaperClaim) *policies.ClaimLister {
+	t.Helper()
+	objects := make([]runtime.Object, 0, len(claims))
+	for _, claim := range claims {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(claim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: content})
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{policies.ClaimGroupVersionResource: policies.CLAIM_KIND + "List"},
+		objects...,
+	)
+
+	claimLister, err := policies.NewClaimClient(dynamicClient).Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claimLister
+}
+
+func TestClaimTTL(t *testing.T) {
+	tests := []struct {
+		ttl      string
+		duration time.Duration
+		isValid  bool
+	}{
+		{ttl: "", duration: 0, isValid: true},
+		{ttl: "3d12h", duration: 84 * time.Hour, isValid: true},
+		{ttl: "90m", duration: 90 * time.Minute, isValid: true},
+		{ttl: "0s"},
+		{ttl: "-1h"},
+		{ttl: "soon"},
+	}
+	for _, tt := range tests {
+		duration, err := claimTTL(newTestClaim("review-login", "claim", tt.ttl))
+		if (err == nil) != tt.isValid || duration != tt.duration {
+			t.Errorf("claimTTL(%q) = %s, %v, expected %s and valid: %v", tt.ttl, duration, err, tt.duration, tt.isValid)
+		}
+	}
+}
+
+func TestNamespaceClaim(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    []*policies.ReaperClaim
+		governing string
+		retention time.Duration
+	}{
+		{
+			name:      "no claims",
+			retention: 7 * 24 * time.Hour,
+		},
+		{
+			name: "first by name",
+			claims: []*policies.ReaperClaim{
+				newTestClaim("review-login", "b", "2d"),
+				newTestClaim("review-login", "a", "1d"),
+			},
+			governing: "a",
+			retention: 24 * time.Hour,
+		},
+		{
+			name: "invalid claims are skipped",
+			claims: []*policies.ReaperClaim{
+				newTestClaim("review-login", "a", "soon"),
+				newTestClaim("review-login", "b", "2d"),
+			},
+			governing: "b",
+			retention: 2 * 24 * time.Hour,
+		},
+		{
+			name: "claim without ttl keeps the retention",
+			claims: []*policies.ReaperClaim{
+				newTestClaim("review-login", "a", ""),
+			},
+			governing: "a",
+			retention: 7 * 24 * time.Hour,
+		},
+		{
+			name: "ttl is capped by the retention",
+			claims: []*policies.ReaperClaim{
+				newTestClaim("review-login", "a", "30d"),
+			},
+			governing: "a",
+			retention: 7 * 24 * time.Hour,
+		},
+		{
+			name: "claims of other namespaces",
+			claims: []*policies.ReaperClaim{
+				newTestClaim("review-other", "a", "1d"),
+			},
+			retention: 7 * 24 * time.Hour,
+		},
+		{
+			name: "all claims invalid",
+			claims: []*policies.ReaperClaim{
+				newTestClaim("review-login", "a", "0s"),
+			},
+			retention: 7 * 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := newTestNamespace("review-login", nil)
+			n, _ := newTestInformer(t, newTestConfig(), ns)
+			n.claimLister = newTestClaimLister(t, tt.claims...)
+
+			governing := ""
+			if claim := n.namespaceClaim(ns); claim != nil {
+				governing = claim.Name
+			}
+			if governing != tt.governing {
+				t.Errorf("expected claim %q, got %q", tt.governing, governing)
+			}
+			if retention := n.policyFor(ns).Retention; retention != tt.retention {
+				t.Errorf("expected retention %s, got %s", tt.retention, retention)
+			}
+		})
+	}
+}
+
+func TestReconcileClaimPhase(t *testing.T) {
+	ns := newTestNamespace("review-login", nil)
+	n, _ := newTestInformer(t, newTestConfig(), ns)
+	claims := []*policies.ReaperClaim{
+		newTestClaim("review-login", "a", "soon"),
+		newTestClaim("review-login", "b", "2d"),
+		newTestClaim("review-login", "c", "1d"),
+	}
+	n.claimLister = newTestClaimLister(t, claims...)
+
+	tests := []struct {
+		claim   *policies.ReaperClaim
+		phase   string
+		message string
+	}{
+		{claim: claims[0], phase: CLAIM_PHASE_INVALID, message: `Invalid ttl, expected a positive duration like 3d12h, got "soon"`},
+		{claim: claims[2], phase: CLAIM_PHASE_IGNORED, message: "Namespace is claimed by b"},
+	}
+	for _, tt := range tests {
+		status := n.reconcileClaim(context.Background(), tt.claim, ns)
+		if status.Phase != tt.phase || status.Message != tt.message {
+			t.Errorf("claim %s: expected %s %q, got %s %q", tt.claim.Name, tt.phase, tt.message, status.Phase, status.Message)
+		}
+	}
+}
+
+func TestReconcileDeletedClaim(t *testing.T) {
+	ns := newTestNamespace("review-login", nil)
+	n, _ := newTestInformer(t, newTestConfig(), ns)
+	n.claimLister = newTestClaimLister(t)
+
+	claim := newTestClaim("review-login", "a", "1d")
+	claim.Status = policies.ReaperClaimStatus{ObservedGeneration: 1, Phase: "Active"}
+	if status := n.reconcileClaim(context.Background(), claim, ns); status != claim.Status {
+		t.Errorf("expected the status of a deleted claim to be kept, got %+v", status)
+	}
+}
+
+func TestReconcileClaimRetriesDeletion(t *testing.T) {
+	ns := newTestNamespace("review-login", nil)
+	n, client := newTestInformer(t, newTestConfig(), ns)
+	claim := newTestClaim("review-login", "a", "1d")
+	claim.Generation = 2
+	claim.Status.ObservedGeneration = 1
+	n.claimLister = newTestClaimLister(t, claim)
+
+	isForbidden := true
+	client.PrependReactor("update", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return isForbidden, nil, errors.New("namespaces is forbidden")
+	})
+	if status := n.reconcileClaim(context.Background(), claim, ns); status.ObservedGeneration != 1 {
+		t.Errorf("expected generation 2 not to be observed until the deletion is set, got %d", status.ObservedGeneration)
+	}
+
+	isForbidden = false
+	status := n.reconcileClaim(context.Background(), claim, ns)
+	if status.ObservedGeneration != 2 {
+		t.Errorf("expected generation 2 to be observed, got %d", status.ObservedGeneration)
+	}
+	if deleteAfter := getTestNamespace(t, client, "review-login").Annotations["delete_after"]; deleteAfter == "" {
+		t.Error("expected the deletion timestamp of the claim")
+	}
+}
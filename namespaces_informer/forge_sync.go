@@ -0,0 +1,56 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// expireStaleSourceRefs is the fallback for lost forge webhooks: every watched namespace
+// whose source-ref annotation points to a branch which no longer exists is expired.
+func (n *NsInformer) expireStaleSourceRefs(
+	ctx context.Context,
+	watchedNamespaces []*corev1.Namespace,
+) error {
+	branches, err := n.forgeClient.ListBranches(ctx)
+	if err != nil {
+		n.logger.Error("Could not list branches from forge", "ERROR:", err)
+		return err
+	}
+	// An empty list is much more likely a misconfigured token or repository than
+	// a repository without branches, so nothing is expired in this case.
+	if len(branches) == 0 {
+		n.logger.Warn(
+			"Forge returned no branches, skipping stale source refs check",
+			"Repository",
+			n.config().Forge.Repository,
+		)
+		return nil
+	}
+
+	for _, ns := range watchedNamespaces {
+		sourceRef := n.getNsSourceRef(ns)
+		if sourceRef == "" || utils.IsContains(branches, sourceRef) {
+			continue
+		}
+		if _, ok := ns.Annotations[n.config().NsExpiredByAnnotation]; ok {
+			continue
+		}
+
+		if n.config().DryRun {
+			n.logger.Info("[DRY-RUN] want to expire, source branch is gone", "namespace", ns.Name, "SourceRef", sourceRef)
+			continue
+		}
+		if err := n.expireNamespace(ctx, ns, "forge"); err != nil {
+			continue
+		}
+		n.logger.Info("Source branch is gone, marked as expired", "NsName", ns.Name, "SourceRef", sourceRef)
+	}
+
+	return nil
+}
+
+func (n *NsInformer) getNsSourceRef(ns *corev1.Namespace) string {
+	return nsMetaValue(ns, n.config().NsSourceRefAnnotation)
+}
@@ -0,0 +1,95 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/forge"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// newTestForge serves the branches one per page from a GitHub-like API.
+func newTestForge(t *testing.T, branches ...string) *forge.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageBranches := make([]map[string]string, 0)
+		if page >= 1 && page <= len(branches) {
+			pageBranches = append(pageBranches, map[string]string{"name": branches[page-1]})
+		}
+		json.NewEncoder(w).Encode(pageBranches)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := forge.NewClient(forge.GITHUB, server.URL, "acme/shop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestExpireStaleSourceRefs(t *testing.T) {
+	newNamespaces := func() []*corev1.Namespace {
+		return []*corev1.Namespace{
+			newTestNamespace("review-main", map[string]string{"review-reaper/source-ref": "main"}),
+			newTestNamespace("review-gone", map[string]string{"review-reaper/source-ref": "feature/gone"}),
+			newTestNamespace("review-no-ref", nil),
+			newTestNamespace("review-expired", map[string]string{
+				"review-reaper/source-ref": "feature/gone",
+				"review-reaper/expired-by": "webhook",
+				"delete_after":             "2000-01-01T00:00:00Z",
+			}),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		branches []string
+		dryRun   bool
+		expired  []string
+	}{
+		{
+			name:     "gone branch",
+			branches: []string{"main", "feature/kept", "feature/other"},
+			expired:  []string{"review-gone"},
+		},
+		{
+			name:     "no branches",
+			branches: nil,
+		},
+		{
+			name:     "dry run",
+			branches: []string{"main"},
+			dryRun:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.DryRun = tt.dryRun
+			namespaces := newNamespaces()
+			n, client := newTestInformer(t, config, namespaces...)
+			n.forgeClient = newTestForge(t, tt.branches...)
+
+			if err := n.expireStaleSourceRefs(context.Background(), namespaces); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, ns := range namespaces {
+				expiredBy := getTestNamespace(t, client, ns.Name).Annotations[config.NsExpiredByAnnotation]
+				wantExpiredBy := ns.Annotations[config.NsExpiredByAnnotation]
+				if utils.IsContains(tt.expired, ns.Name) {
+					wantExpiredBy = "forge"
+				}
+				if expiredBy != wantExpiredBy {
+					t.Errorf("namespace %s expired by %q, expected %q", ns.Name, expiredBy, wantExpiredBy)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package namespaces_informer
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	HIBERNATION_TICK     = time.Minute
+	HibernatedAnnotation = "review-reaper/hibernated-at"
+	ReplicasAnnotation   = "review-reaper/replicas"
+	KIND_DEPLOYMENT      = "Deployment"
+	KIND_STATEFULSET     = "StatefulSet"
+)
+
+// workload is a scalable object in a review namespace, either a Deployment or a StatefulSet.
+type workload struct {
+	kind        string
+	name        string
+	replicas    int32
+	annotations map[string]string
+	patch       func(ctx context.Context, patch []byte) error
+}
+
+// HibernationTicker scales watched namespaces to zero outside of working hours and back when they start.
+func (n *NsInformer) HibernationTicker(ctx context.Context) {
+	ticker := time.NewTicker(HIBERNATION_TICK)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Finishig hibernation ticker...")
+			return
+		case <-ticker.C:
+			n.reconcileHibernation(ctx)
+		}
+	}
+}
+
+func (n *NsInformer) reconcileHibernation(ctx context.Context) {
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		n.logger.Error("Could not list watched namespaces for hibernation", err)
+		return
+	}
+
+	isWorkingHours := n.isWorkingHours(time.Now().UTC())
+
+	for _, ns := range watchedNamespaces {
+		// Quarantined namespaces are kept scaled down until deletion or restore.
+		if _, ok := ns.Annotations[n.config().NsQuarantineAnnotation]; ok {
+			continue
+		}
+		_, isHibernated := ns.Annotations[HibernatedAnnotation]
+		_, isWakeRequested := ns.Annotations[n.config().NsWakeAnnotation]
+
+		switch {
+		case !isWorkingHours && !isWakeRequested && !isHibernated:
+			n.hibernateNamespace(ctx, ns)
+		case isHibernated && (isWorkingHours || isWakeRequested):
+			n.wakeNamespace(ctx, ns, isWorkingHours)
+		case isWorkingHours && isWakeRequested:
+			// Wake request is served, the namespace should hibernate again after working hours.
+			n.removeNsAnnotations(ctx, ns, n.config().NsWakeAnnotation)
+		}
+	}
+}
+
+// isWorkingHours is the inverse of the hibernation window.
+func (n *NsInformer) isWorkingHours(t time.Time) bool {
+	return n.isInWindow(n.config().Hibernation.WorkingHours, t)
+}
+
+func (n *NsInformer) hibernateNamespace(ctx context.Context, ns *corev1.Namespace) error {
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to hibernate", "namespace", ns.Name)
+		return nil
+	}
+
+	scaled, err := n.scaleDownWorkloads(ctx, ns)
+	if err != nil {
+		return err
+	}
+
+	err = n.updateNsAnnotations(ctx, ns, map[string]string{
+		HibernatedAnnotation: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err == nil {
+		n.logger.Info("Namespace hibernated", "namespace", ns.Name, "Workloads", scaled)
+	}
+	return err
+}
+
+// scaleDownWorkloads scales all running workloads of the namespace to zero, remembering
+// original replicas. Workloads which are already scaled down by the reaper are left as is.
+func (n *NsInformer) scaleDownWorkloads(ctx context.Context, ns *corev1.Namespace) (int, error) {
+	workloads, err := n.listWorkloads(ctx, ns.Name)
+	if err != nil {
+		n.logger.Error("Could not list workloads to scale down", "namespace", ns.Name, "ERROR:", err)
+		return 0, err
+	}
+
+	scaled := 0
+	for _, w := range workloads {
+		if w.replicas == 0 {
+			continue
+		}
+		if _, ok := w.annotations[ReplicasAnnotation]; ok {
+			continue
+		}
+		if err := n.scaleWorkload(ctx, w, strconv.Quote(strconv.Itoa(int(w.replicas))), 0); err != nil {
+			return scaled, err
+		}
+		scaled++
+	}
+	return scaled, nil
+}
+
+// wakeNamespace restores original replica counts. The wake request annotation is dropped
+// together with the hibernation mark when working hours have started.
+func (n *NsInformer) wakeNamespace(
+	ctx context.Context,
+	ns *corev1.Namespace,
+	isWorkingHours bool,
+) error {
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to wake up", "namespace", ns.Name)
+		return nil
+	}
+
+	if err := n.restoreWorkloads(ctx, ns); err != nil {
+		return err
+	}
+
+	doneAnnotations := []string{HibernatedAnnotation}
+	if isWorkingHours {
+		doneAnnotations = append(doneAnnotations, n.config().NsWakeAnnotation)
+	}
+	err := n.removeNsAnnotations(ctx, ns, doneAnnotations...)
+	if err == nil {
+		n.logger.Info("Namespace woken up", "namespace", ns.Name)
+	}
+	return err
+}
+
+func (n *NsInformer) restoreWorkloads(ctx context.Context, ns *corev1.Namespace) error {
+	workloads, err := n.listWorkloads(ctx, ns.Name)
+	if err != nil {
+		n.logger.Error("Could not list workloads to restore", "namespace", ns.Name, "ERROR:", err)
+		return err
+	}
+
+	for _, w := range workloads {
+		original, ok := w.annotations[ReplicasAnnotation]
+		if !ok {
+			continue
+		}
+		replicas, err := strconv.Atoi(original)
+		if err != nil {
+			n.logger.Error("Invalid original replicas annotation", "namespace", ns.Name, w.kind, w.name)
+			continue
+		}
+		if err := n.scaleWorkload(ctx, w, "null", int32(replicas)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleWorkload sets replicas and the original replicas annotation in a single merge patch.
+// annotationValue is a JSON value, "null" removes the annotation.
+func (n *NsInformer) scaleWorkload(
+	ctx context.Context,
+	w workload,
+	annotationValue string,
+	replicas int32,
+) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]json.RawMessage{
+				ReplicasAnnotation: json.RawMessage(annotationValue),
+			},
+		},
+		"spec": map[string]interface{}{"replicas": replicas},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := w.patch(ctx, patch); err != nil {
+		n.logger.Error("Could not scale", w.kind, w.name, "Replicas", replicas, "ERROR:", err)
+		return err
+	}
+	n.logger.Debug("Scaled", w.kind, w.name, "Replicas", replicas)
+	return nil
+}
+
+func (n *NsInformer) listWorkloads(ctx context.Context, namespace string) ([]workload, error) {
+	workloads := make([]workload, 0)
+	listOptions := metav1.ListOptions{}
+
+	deploymentsClient := n.client.AppsV1().Deployments(namespace)
+	deployments, err := deploymentsClient.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		name := d.Name
+		workloads = append(workloads, workload{
+			kind:        KIND_DEPLOYMENT,
+			name:        name,
+			replicas:    replicasOrDefault(d.Spec.Replicas),
+			annotations: d.Annotations,
+			patch: func(ctx context.Context, patch []byte) error {
+				_, err := deploymentsClient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+				return err
+			},
+		})
+	}
+
+	statefulSetsClient := n.client.AppsV1().StatefulSets(namespace)
+	statefulSets, err := statefulSetsClient.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		name := s.Name
+		workloads = append(workloads, workload{
+			kind:        KIND_STATEFULSET,
+			name:        name,
+			replicas:    replicasOrDefault(s.Spec.Replicas),
+			annotations: s.Annotations,
+			patch: func(ctx context.Context, patch []byte) error {
+				_, err := statefulSetsClient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+				return err
+			},
+		})
+	}
+
+	return workloads, nil
+}
+
+// replicasOrDefault mirrors the API server default of one replica for an unset field.
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,156 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newHibernationTestConfig has working hours all week, or no working hours at all.
+func newHibernationTestConfig(isWorkingHours bool) utils.Config {
+	config := newTestConfig()
+	config.Hibernation.Enabled = true
+	config.Hibernation.WorkingHours.NotBefore = "00:00"
+	config.Hibernation.WorkingHours.NotAfter = "23:59"
+	if isWorkingHours {
+		config.Hibernation.WorkingHours.WeekDays = config.DeletionWindow.WeekDays
+	}
+	return config
+}
+
+func createTestDeployment(t *testing.T, client *fake.Clientset, namespace string, replicas int32, annotations map[string]string) {
+	t.Helper()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace, Annotations: annotations},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+	if _, err := client.AppsV1().Deployments(namespace).Create(context.Background(), deployment, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getTestDeployment(t *testing.T, client *fake.Clientset, namespace string) *appsv1.Deployment {
+	t.Helper()
+	deployment, err := client.AppsV1().Deployments(namespace).Get(context.Background(), "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
+
+func TestHibernateOutsideWorkingHours(t *testing.T) {
+	n, client := newTestInformer(
+		t,
+		newHibernationTestConfig(false),
+		newTestNamespace("review-running", nil),
+		newTestNamespace("review-stopped", nil),
+		newTestNamespace("production", nil),
+	)
+	createTestDeployment(t, client, "review-running", 3, nil)
+	createTestDeployment(t, client, "review-stopped", 0, nil)
+	createTestDeployment(t, client, "production", 2, nil)
+	replicas := int32(1)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "review-running"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+	if _, err := client.AppsV1().StatefulSets("review-running").Create(context.Background(), statefulSet, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	n.reconcileHibernation(context.Background())
+
+	for _, name := range []string{"review-running", "review-stopped"} {
+		if _, ok := getTestNamespace(t, client, name).Annotations[HibernatedAnnotation]; !ok {
+			t.Errorf("expected %s to be hibernated", name)
+		}
+	}
+	if deployment := getTestDeployment(t, client, "review-running"); *deployment.Spec.Replicas != 0 || deployment.Annotations[ReplicasAnnotation] != "3" {
+		t.Errorf("expected the deployment scaled down from 3, got %d %v", *deployment.Spec.Replicas, deployment.Annotations)
+	}
+	scaledSet, err := client.AppsV1().StatefulSets("review-running").Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *scaledSet.Spec.Replicas != 0 || scaledSet.Annotations[ReplicasAnnotation] != "1" {
+		t.Errorf("expected the statefulset scaled down from 1, got %d %v", *scaledSet.Spec.Replicas, scaledSet.Annotations)
+	}
+	// A workload which is already stopped is not woken up with the namespace.
+	if _, ok := getTestDeployment(t, client, "review-stopped").Annotations[ReplicasAnnotation]; ok {
+		t.Error("expected the stopped deployment to keep no original replicas")
+	}
+
+	if _, ok := getTestNamespace(t, client, "production").Annotations[HibernatedAnnotation]; ok {
+		t.Error("expected production not to be hibernated")
+	}
+	if *getTestDeployment(t, client, "production").Spec.Replicas != 2 {
+		t.Error("expected the production deployment to keep running")
+	}
+}
+
+func TestWakeInWorkingHours(t *testing.T) {
+	config := newHibernationTestConfig(true)
+	n, client := newTestInformer(
+		t,
+		config,
+		newTestNamespace("review-login", map[string]string{
+			HibernatedAnnotation:    time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+			config.NsWakeAnnotation: "true",
+		}),
+	)
+	createTestDeployment(t, client, "review-login", 0, map[string]string{ReplicasAnnotation: "3"})
+
+	n.reconcileHibernation(context.Background())
+
+	deployment := getTestDeployment(t, client, "review-login")
+	if _, ok := deployment.Annotations[ReplicasAnnotation]; ok || *deployment.Spec.Replicas != 3 {
+		t.Errorf("expected the deployment scaled back to 3, got %d %v", *deployment.Spec.Replicas, deployment.Annotations)
+	}
+	// The wake request is served by the working hours.
+	annotations := getTestNamespace(t, client, "review-login").Annotations
+	for _, key := range []string{HibernatedAnnotation, config.NsWakeAnnotation} {
+		if _, ok := annotations[key]; ok {
+			t.Errorf("expected %s to be removed", key)
+		}
+	}
+}
+
+func TestWakeRequestOutsideWorkingHours(t *testing.T) {
+	config := newHibernationTestConfig(false)
+	n, client := newTestInformer(
+		t,
+		config,
+		newTestNamespace("review-hibernated", map[string]string{
+			HibernatedAnnotation:    time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+			config.NsWakeAnnotation: "true",
+		}),
+		newTestNamespace("review-awake", map[string]string{config.NsWakeAnnotation: "true"}),
+	)
+	createTestDeployment(t, client, "review-hibernated", 0, map[string]string{ReplicasAnnotation: "2"})
+	createTestDeployment(t, client, "review-awake", 2, nil)
+
+	n.reconcileHibernation(context.Background())
+
+	deployment := getTestDeployment(t, client, "review-hibernated")
+	if _, ok := deployment.Annotations[ReplicasAnnotation]; ok || *deployment.Spec.Replicas != 2 {
+		t.Errorf("expected the deployment scaled back to 2, got %d %v", *deployment.Spec.Replicas, deployment.Annotations)
+	}
+	// The wake request is kept until working hours, so the namespace does not hibernate again.
+	for _, name := range []string{"review-hibernated", "review-awake"} {
+		annotations := getTestNamespace(t, client, name).Annotations
+		if _, ok := annotations[HibernatedAnnotation]; ok {
+			t.Errorf("expected %s to be awake", name)
+		}
+		if _, ok := annotations[config.NsWakeAnnotation]; !ok {
+			t.Errorf("expected %s to keep the wake request", name)
+		}
+	}
+	if *getTestDeployment(t, client, "review-awake").Spec.Replicas != 2 {
+		t.Error("expected the awake deployment to keep running")
+	}
+}
@@ -1,10 +1,15 @@
 package namespaces_informer
 
 import (
+	"NaNameUz3r/ReviewReaper/backup"
+	"NaNameUz3r/ReviewReaper/forge"
 	"NaNameUz3r/ReviewReaper/logs"
+	"NaNameUz3r/ReviewReaper/policies"
 	"NaNameUz3r/ReviewReaper/utils"
 	"context"
+	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -16,6 +21,8 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	listers "k8s.io/client-go/listers/core/v1"
@@ -25,23 +32,44 @@ import (
 
 const (
 	HH_MM          = "15:04"
-	RFC3339local   = "2006-01-02T15:04:05Z"
 	TICK_SECONDS   = 5
 	RESYNC_TIMEOUT = 15 * time.Minute
 )
 
 type NsInformer struct {
 	restConfig *rest.Config
-	client     *kubernetes.Clientset
+	client     kubernetes.Interface
 	logger     logs.Logger
-	appConfig  utils.Config
-
-	nsLister listers.NamespaceLister
+	appConfig  *utils.Config
+
+	nsLister      listers.NamespaceLister
+	podLister     listers.PodLister
+	forgeClient   *forge.Client
+	dynamicClient dynamic.Interface
+	backupStore   backup.Store
+	throttle      *adaptiveThrottle
+	policyClient  *policies.Client
+	policyLister  *policies.Lister
+	claimClient   *policies.ClaimClient
+	claimLister   *policies.ClaimLister
+
+	terminating      map[string]time.Time
+	terminatingMutex sync.Mutex
+
+	quotaEvicted map[string]bool
+	quotaMutex   sync.Mutex
+
+	configMutex sync.RWMutex
+
+	policies        []*compiledPolicy
+	policiesMutex   sync.RWMutex
+	policiesChanged chan struct{}
+	claimsChanged   chan struct{}
 }
 
 func NewNsInformer(
 	restConfig *rest.Config,
-	client *kubernetes.Clientset,
+	client kubernetes.Interface,
 	logger logs.Logger,
 	appConfig utils.Config,
 ) *NsInformer {
@@ -49,11 +77,81 @@ func NewNsInformer(
 		restConfig: restConfig,
 		client:     client,
 		logger:     logger,
-		appConfig:  appConfig,
+		appConfig:  &appConfig,
+
+		terminating:     make(map[string]time.Time),
+		quotaEvicted:    make(map[string]bool),
+		policiesChanged: make(chan struct{}, 1),
+		claimsChanged:   make(chan struct{}, 1),
 	}
 }
 
+// config returns the current config, it is swapped as a whole on reload and must not be modified.
+func (n *NsInformer) config() *utils.Config {
+	n.configMutex.RLock()
+	defer n.configMutex.RUnlock()
+	return n.appConfig
+}
+
 func (n *NsInformer) Run(ctx context.Context) error {
+	err := n.setup(ctx, cache.ResourceEventHandlerFuncs{
+		AddFunc:    n.onAddNamespace(ctx),
+		UpdateFunc: n.onUpdateNamespace(ctx),
+		DeleteFunc: n.onDeleteNamespace,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.watchConfig(ctx)
+
+	if n.config().Webhook.Enabled {
+		go n.serveWebhooks(ctx)
+	}
+
+	if n.config().AdminAPI.Enabled {
+		go n.serveAdminAPI(ctx)
+	}
+
+	if n.config().Admission.Enabled {
+		go n.serveAdmission(ctx)
+	}
+
+	if n.config().Hibernation.Enabled {
+		go n.HibernationTicker(ctx)
+	}
+
+	if n.config().TerminationWatch.Enabled {
+		go n.TerminationTicker(ctx)
+	}
+
+	if n.config().Budget.Enabled {
+		go n.BudgetTicker(ctx)
+	}
+
+	if n.config().Policies.Enabled {
+		go n.PolicyTicker(ctx)
+	}
+
+	if n.config().Claims.Enabled {
+		go n.ClaimTicker(ctx)
+	}
+
+	go n.ProtectionTicker(ctx)
+	go n.DeletionTicker(ctx)
+
+	return nil
+}
+
+// setup creates the clients and starts the informers, waiting for their caches to sync.
+// The namespace event handler is nil in one-shot mode.
+func (n *NsInformer) setup(ctx context.Context, handler cache.ResourceEventHandler) error {
+	if n.config().Throttle.Adaptive {
+		if err := n.setupThrottle(); err != nil {
+			return err
+		}
+	}
+
 	informerFactory := informers.NewSharedInformerFactory(n.client, RESYNC_TIMEOUT)
 
 	factoryNsInformer := informerFactory.Core().V1().Namespaces()
@@ -62,20 +160,66 @@ func (n *NsInformer) Run(ctx context.Context) error {
 
 	n.nsLister = namespaceLister
 
-	namespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    n.onAddNamespace(ctx),
-		UpdateFunc: n.onUpdateNamespace(ctx),
-		DeleteFunc: func(interface{}) { return },
-	})
+	cacheSyncs := []cache.InformerSynced{namespaceInformer.HasSynced}
+	if n.config().Budget.Enabled {
+		factoryPodInformer := informerFactory.Core().V1().Pods()
+		cacheSyncs = append(cacheSyncs, factoryPodInformer.Informer().HasSynced)
+		n.podLister = factoryPodInformer.Lister()
+	}
+
+	if n.config().Forge.Enabled {
+		forgeClient, err := forge.NewClient(
+			n.config().Forge.Type,
+			n.config().Forge.BaseURL,
+			n.config().Forge.Repository,
+			n.config().Forge.Token,
+		)
+		if err != nil {
+			return err
+		}
+		n.forgeClient = forgeClient
+	}
+
+	if n.config().Backup.Enabled {
+		if err := n.setupBackup(); err != nil {
+			return err
+		}
+	}
+
+	// Teardown stages might be added by config reload, so the dynamic client is always there.
+	if err := n.setupDynamicClient(); err != nil {
+		return err
+	}
+
+	if n.config().Policies.Enabled {
+		policiesSynced, err := n.setupPolicies(ctx, handler != nil)
+		if err != nil {
+			return err
+		}
+		cacheSyncs = append(cacheSyncs, policiesSynced)
+	}
+
+	if n.config().Claims.Enabled {
+		claimsSynced, err := n.setupClaims(ctx, handler != nil)
+		if err != nil {
+			return err
+		}
+		cacheSyncs = append(cacheSyncs, claimsSynced)
+	}
+
+	if handler != nil {
+		namespaceInformer.AddEventHandler(handler)
+	}
 
 	// start informer ->
 	go informerFactory.Start(ctx.Done())
 	// start to sync and call list
-	if !cache.WaitForCacheSync(ctx.Done(), namespaceInformer.HasSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), cacheSyncs...) {
 		return errors.New("Timeout occurred while waiting for caches to synchronize")
 	}
-
-	go n.DeletionTicker(ctx)
+	if n.policyLister != nil {
+		n.setPolicies(n.policyLister.List())
+	}
 
 	return nil
 }
@@ -85,6 +229,9 @@ func (n *NsInformer) onAddNamespace(ctx context.Context) func(interface{}) {
 		namespace := obj.(*corev1.Namespace)
 		if n.isWatched(namespace) {
 			n.ensureAnnotated(ctx, namespace)
+			if n.config().Quota.Enabled {
+				n.enforceQuota(ctx, namespace)
+			}
 		}
 	}
 }
@@ -92,6 +239,12 @@ func (n *NsInformer) onAddNamespace(ctx context.Context) func(interface{}) {
 func (n *NsInformer) onUpdateNamespace(ctx context.Context) func(interface{}, interface{}) {
 	return func(oldObj interface{}, newObj interface{}) {
 		newNamespace := newObj.(*corev1.Namespace)
+		oldNamespace := oldObj.(*corev1.Namespace)
+
+		if n.config().Quarantine.Enabled && n.isQuarantineLifted(oldNamespace, newNamespace) {
+			n.restoreFromQuarantine(ctx, newNamespace)
+			return
+		}
 
 		if n.isWatched(newNamespace) {
 			n.ensureAnnotated(ctx, newNamespace)
@@ -99,19 +252,39 @@ func (n *NsInformer) onUpdateNamespace(ctx context.Context) func(interface{}, in
 	}
 }
 
+func (n *NsInformer) onDeleteNamespace(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+	n.forgetTermination(namespace)
+	n.forgetEviction(namespace)
+}
+
 func (n *NsInformer) isWatched(namespace *corev1.Namespace) bool {
-	isNameWatched := n.appConfig.DeletionRegexp.MatchString(namespace.Name)
-	_, ok := namespace.Annotations[n.appConfig.NsPreserveAnnotation]
-	return isNameWatched && !ok
+	return n.isSelected(namespace) && !n.isProtected(namespace)
+}
+
+// isSelected tells whether the namespace name matches NsNameDeletionRegexp, a ReaperPolicy selects it
+// or a ReaperClaim claims it, no matter if it is protected.
+func (n *NsInformer) isSelected(namespace *corev1.Namespace) bool {
+	return n.config().DeletionRegexp.MatchString(namespace.Name) ||
+		n.selectingPolicy(namespace) != nil ||
+		n.namespaceClaim(namespace) != nil
 }
 
 func (n *NsInformer) ensureAnnotated(ctx context.Context, ns *corev1.Namespace) error {
 	annotations := n.getNsAnnotations(ns)
-	_, ok := annotations[n.appConfig.AnnotationKey]
+	_, ok := annotations[n.config().AnnotationKey]
 	if !ok {
 		createdAt := n.getNsCreationTimestamp(ns)
-		decommissionTimestamp := n.shiftTimeStampByRetention(createdAt).UTC().Format(time.RFC3339)
-		n.annotateRetention(ctx, ns, decommissionTimestamp)
+		decommissionTimestamp := n.shiftTimeStampByRetention(ns, createdAt).UTC().Format(time.RFC3339)
+		if err := n.annotateRetention(ctx, ns, decommissionTimestamp); err != nil {
+			return err
+		}
 		n.logger.Info(
 			"Annotated for deletion",
 			"NsName",
@@ -119,6 +292,7 @@ func (n *NsInformer) ensureAnnotated(ctx context.Context, ns *corev1.Namespace)
 			"DeletionTimestamp",
 			decommissionTimestamp,
 		)
+		n.notifyPolicyEvent(ctx, ns, "ScheduledForDeletion", "Namespace is going to be deleted after "+decommissionTimestamp)
 	}
 
 	return nil
@@ -129,7 +303,32 @@ func (n *NsInformer) annotateRetention(
 	ns *corev1.Namespace,
 	annotationValue string,
 ) error {
-	if ns.ObjectMeta.Annotations[n.appConfig.AnnotationKey] == annotationValue {
+	return n.updateNsAnnotations(ctx, ns, map[string]string{
+		n.config().AnnotationKey: annotationValue,
+	})
+}
+
+// expireNamespace moves the deletion timestamp to now and records the reason,
+// so the namespace is deleted in the next window and is not postponed by Helm activity.
+func (n *NsInformer) expireNamespace(ctx context.Context, ns *corev1.Namespace, reason string) error {
+	return n.updateNsAnnotations(ctx, ns, map[string]string{
+		n.config().AnnotationKey:         time.Now().UTC().Format(time.RFC3339),
+		n.config().NsExpiredByAnnotation: reason,
+	})
+}
+
+func (n *NsInformer) updateNsAnnotations(
+	ctx context.Context,
+	ns *corev1.Namespace,
+	newAnnotations map[string]string,
+) error {
+	isChanged := false
+	for key, value := range newAnnotations {
+		if ns.ObjectMeta.Annotations[key] != value {
+			isChanged = true
+		}
+	}
+	if !isChanged {
 		return nil
 	}
 
@@ -140,7 +339,9 @@ func (n *NsInformer) annotateRetention(
 		annotations = make(map[string]string)
 	}
 
-	annotations[n.appConfig.AnnotationKey] = annotationValue
+	for key, value := range newAnnotations {
+		annotations[key] = value
+	}
 
 	newNs.ObjectMeta.Annotations = annotations
 
@@ -149,7 +350,53 @@ func (n *NsInformer) annotateRetention(
 	if err != nil {
 		n.logger.Error("Unable to annotate", "NsName", ns.Name, "ERROR:", err)
 	}
-	return nil
+	return err
+}
+
+// removeNsAnnotations uses a merge patch, so it does not conflict with updates
+// made to the same namespace earlier in the iteration.
+func (n *NsInformer) removeNsAnnotations(
+	ctx context.Context,
+	ns *corev1.Namespace,
+	keys ...string,
+) error {
+	removed := make(map[string]interface{})
+	for _, key := range keys {
+		if _, ok := ns.Annotations[key]; ok {
+			removed[key] = nil
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	return n.patchNsAnnotations(ctx, ns, removed)
+}
+
+// patchNsAnnotations applies annotations as a merge patch, nil values remove the annotation.
+func (n *NsInformer) patchNsAnnotations(
+	ctx context.Context,
+	ns *corev1.Namespace,
+	annotations map[string]interface{},
+) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = n.client.CoreV1().Namespaces().Patch(
+		ctx,
+		ns.Name,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{},
+	)
+	if err != nil {
+		n.logger.Error("Unable to annotate", "NsName", ns.Name, "ERROR:", err)
+	}
+	return err
 }
 
 func (n *NsInformer) DeletionTicker(ctx context.Context) {
@@ -168,24 +415,8 @@ func (n *NsInformer) DeletionTicker(ctx context.Context) {
 					tickTime.UTC().Format(time.RFC822),
 				)
 			}
-			watchedNamespaces, err := n.listWatchedNamespaces()
-			if err != nil {
-				n.logger.Error("Could not list watched namespaces for deletion", err)
-			}
-			if n.appConfig.PostponeDeletion && len(watchedNamespaces) > 0 {
-				n.postponeDelOfActive(ctx, watchedNamespaces)
-			}
-
-			expiredNamespaces := n.filterExpiredNamespaces(watchedNamespaces)
-
-			if len(expiredNamespaces) > 0 {
-				n.logger.Info("Found expired namespaces", "Count", len(expiredNamespaces))
-
-				err = n.processExpiredNamespaces(ctx, expiredNamespaces)
-				if err != nil {
-					n.logger.Error("Could not process expired namespaces", err)
-				}
-			} else {
+			summary, _ := n.maintenanceIteration(ctx)
+			if summary.Expired == 0 {
 				n.logger.Info("Nothing to delete.")
 				n.logger.Info("Taking a nap for 15 minutes...")
 				time.Sleep(time.Minute * 15)
@@ -203,27 +434,89 @@ func (n *NsInformer) DeletionTicker(ctx context.Context) {
 	n.logger.Info("Finishig deletion ticker...")
 }
 
+// maintenanceIteration postpones deletion of active namespaces and deletes expired ones,
+// it is the body of the deletion ticker and the one-shot run.
+func (n *NsInformer) maintenanceIteration(ctx context.Context) (RunSummary, error) {
+	summary := RunSummary{InWindow: true}
+
+	restored := make(map[string]bool)
+	if n.config().Quarantine.Enabled {
+		restored = n.restoreLiftedQuarantines(ctx)
+	}
+
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		n.logger.Error("Could not list watched namespaces for deletion", err)
+	}
+	// Restored namespaces still have the old deletion timestamp in the cache.
+	if len(restored) > 0 {
+		notRestored := make([]*corev1.Namespace, 0, len(watchedNamespaces))
+		for _, ns := range watchedNamespaces {
+			if !restored[ns.Name] {
+				notRestored = append(notRestored, ns)
+			}
+		}
+		watchedNamespaces = notRestored
+	}
+	summary.Watched = len(watchedNamespaces)
+
+	if len(watchedNamespaces) > 0 {
+		summary.Postponed, _ = n.postponeDelOfActive(ctx, watchedNamespaces)
+	}
+	if n.forgeClient != nil && len(watchedNamespaces) > 0 {
+		n.expireStaleSourceRefs(ctx, watchedNamespaces)
+	}
+
+	expiredNamespaces := n.filterExpiredNamespaces(watchedNamespaces)
+	// Quarantined namespaces which are not due yet are not expired, so the deletion ticker naps meanwhile.
+	if n.config().Quarantine.Enabled {
+		expiredNamespaces = n.quarantineExpiredNamespaces(ctx, expiredNamespaces)
+	}
+	summary.Expired = len(expiredNamespaces)
+
+	if len(expiredNamespaces) > 0 {
+		n.logger.Info("Found expired namespaces", "Count", len(expiredNamespaces))
+
+		summary.Deleted, err = n.processExpiredNamespaces(ctx, expiredNamespaces)
+		if err != nil {
+			n.logger.Error("Could not process expired namespaces", err)
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// isNowAllowed tells whether the deletion window of the config or of any policy is open.
 func (n *NsInformer) isNowAllowed() bool {
 	timeNow := time.Now().UTC()
+	for _, window := range n.deletionWindows() {
+		if n.isInWindow(window, timeNow) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *NsInformer) isInWindow(window utils.TimeWindow, t time.Time) bool {
 	isAllowed := false
 
-	if n.isTodayAllowed(timeNow) && n.isTimeNowAllowed(timeNow) {
+	if n.isTodayAllowed(window, t) && n.isTimeNowAllowed(window, t) {
 		isAllowed = true
 	}
 
 	return isAllowed
 }
 
-func (n *NsInformer) isTodayAllowed(t time.Time) bool {
+func (n *NsInformer) isTodayAllowed(window utils.TimeWindow, t time.Time) bool {
 	todayWeekday := t.UTC().Weekday().String()[0:3]
-	weekdayOk := utils.IsContains(n.appConfig.DeletionWindow.WeekDays, todayWeekday)
+	weekdayOk := utils.IsContains(window.WeekDays, todayWeekday)
 	return weekdayOk
 }
 
-func (n *NsInformer) isTimeNowAllowed(t time.Time) bool {
+func (n *NsInformer) isTimeNowAllowed(window utils.TimeWindow, t time.Time) bool {
 	isAllowed := false
-	nbCfg, _ := time.Parse(HH_MM, n.appConfig.DeletionWindow.NotBefore)
-	naCfg, _ := time.Parse(HH_MM, n.appConfig.DeletionWindow.NotAfter)
+	nbCfg, _ := time.Parse(HH_MM, window.NotBefore)
+	naCfg, _ := time.Parse(HH_MM, window.NotAfter)
 
 	notBefore := time.Date(
 		t.Year(),
@@ -252,108 +545,50 @@ func (n *NsInformer) isTimeNowAllowed(t time.Time) bool {
 	return isAllowed
 }
 
-func (n *NsInformer) isMaintenanceToday(t time.Time) bool {
-	if !n.isTodayAllowed(t) {
-		return false
-	}
-	nbCfg, _ := time.Parse(HH_MM, n.appConfig.DeletionWindow.NotBefore)
-	nowHHMM, _ := time.Parse(HH_MM, t.Format(HH_MM))
-
-	isLater := nowHHMM.UTC().Before(nbCfg.UTC())
-	return isLater
-}
-
+// durationUntilMaintenance is the time until the earliest of the config and policy windows opens.
 func (n *NsInformer) durationUntilMaintenance() time.Duration {
-	now := time.Now().UTC()
-	nextMaintenanceTime := n.getNextMaintenanceTime(now)
-	timeDifference := time.Until(time.Unix(nextMaintenanceTime.Unix(), 0))
-	return timeDifference
-}
-
-func (n *NsInformer) isTodayLastDayOfTheMonth(t time.Time) bool {
-	today := t.UTC()
-	tomorrow := today.AddDate(0, 0, 1)
-	if tomorrow.Month() != today.Month() {
-		return true
-	}
-	return false
-}
-
-func (n *NsInformer) isTodayLastDayOfTheYear(t time.Time) bool {
-	today := t.UTC()
-	tomorrow := today.AddDate(0, 0, 1)
-	if tomorrow.Year() != today.Year() {
-		return true
-	}
-	return false
-}
-
-func (n *NsInformer) getNextMaintenanceTime(now time.Time) time.Time {
-	currentMonth := now.Month()
-	currentYear := now.Year()
-	var nextDate time.Time
-
-	nbCfg, _ := time.Parse(HH_MM, n.appConfig.DeletionWindow.NotBefore)
-	n.logger.Info("Seeking next allowed maintenance window")
-
-	if n.isMaintenanceToday(now) {
-		nextDate = time.Date(
-			currentYear,
-			currentMonth,
-			int(now.Day()),
-			nbCfg.Hour(),
-			nbCfg.Minute(),
-			0,
-			0, time.UTC)
-		n.logger.Info("Maintenanse will be today later")
-	} else {
-		nextDate = time.Date(
-			currentYear,
-			currentMonth,
-			int(now.Day())+1,
-			nbCfg.Hour(),
-			nbCfg.Minute(),
-			0,
-			0, time.UTC)
-		n.logger.Info("No maintenance window today, will check tomorrow")
-	}
-
-	if n.isTodayLastDayOfTheMonth(now) {
-		nextDate.AddDate(0, 1, 0)
-	}
-
-	if n.isTodayLastDayOfTheMonth(now) {
-		nextDate.AddDate(1, 0, 0)
-	}
-
-	return nextDate
+	return time.Until(n.nextWindowStart(time.Now().UTC()))
 }
 
 func (n *NsInformer) listWatchedNamespaces() (namespaces []*corev1.Namespace, err error) {
-	watchedNamespaces := make([]*corev1.Namespace, 0)
-
 	namespaces, err = n.nsLister.List(labels.Everything())
 	if err != nil {
-		return watchedNamespaces, errors.New("could not list namespaces")
+		return make([]*corev1.Namespace, 0), errors.New("could not list namespaces")
 	}
+	return n.filterWatchedNamespaces(namespaces), err
+}
 
+// filterWatchedNamespaces returns watched namespaces sorted by name,
+// so they are always processed in the same order.
+func (n *NsInformer) filterWatchedNamespaces(namespaces []*corev1.Namespace) []*corev1.Namespace {
+	watchedNamespaces := make([]*corev1.Namespace, 0)
 	for _, ns := range namespaces {
-		if n.isWatched(ns) && ns.Annotations[n.appConfig.NsPreserveAnnotation] != "true" {
+		if n.isWatched(ns) {
 			watchedNamespaces = append(watchedNamespaces, ns)
 		}
 	}
-	return watchedNamespaces, err
+	sort.Slice(watchedNamespaces, func(i, j int) bool {
+		return watchedNamespaces[i].Name < watchedNamespaces[j].Name
+	})
+	return watchedNamespaces
 }
 
 func (n *NsInformer) postponeDelOfActive(
 	ctx context.Context,
 	watchedNamespaces []*corev1.Namespace,
-) error {
+) (int, error) {
+	postponed := 0
 	n.logger.Info(
 		"Comparing the timestamps of the last deployed Helm releases in the watched namespaces with the deletion timestamps of these namespaces",
 	)
 
 	for _, ns := range watchedNamespaces {
+		if _, ok := ns.Annotations[n.config().NsExpiredByAnnotation]; ok {
+			continue
+		}
+		if !n.policyFor(ns).PostponeDeletion {
+			continue
+		}
 
 		nsReleases, _ := n.listNamespaceReleases(ns)
 		if len(nsReleases) <= 0 {
@@ -361,16 +596,8 @@ func (n *NsInformer) postponeDelOfActive(
 		}
 
 		nsDeletionTs, _ := n.getNsDeletionTimespamp(ns)
-		latestRelease := n.latestDeployedRelease(nsReleases)
-
-		latestDeployTs := latestRelease.Info.LastDeployed.UTC().Time
-		considerDeletionTs := latestDeployTs.AddDate(0, 0, n.appConfig.RetentionDays)
-		considerDeletionTs.Add(time.Hour * time.Duration(n.appConfig.RetentionHours))
-
-		truncatedNsDeletionTs := nsDeletionTs.Truncate(time.Second)
-		truncatedConsiderDeletionTs := considerDeletionTs.Truncate(time.Second)
-
-		if truncatedNsDeletionTs.Equal(truncatedConsiderDeletionTs) {
+		considerDeletionTs, _, isPostponed := n.postponedDeletion(ns, nsReleases)
+		if !isPostponed {
 			n.logger.Info(
 				"namespace",
 				ns.Name,
@@ -381,13 +608,34 @@ func (n *NsInformer) postponeDelOfActive(
 			continue
 		}
 
-		if nsDeletionTs.Before(considerDeletionTs) {
-			newRetention := considerDeletionTs.Format(time.RFC3339)
-			n.annotateRetention(ctx, ns, newRetention)
-			n.logger.Info("namespace", ns.Name, "deletion postponed", "for", newRetention)
-		}
+		newRetention := considerDeletionTs.Format(time.RFC3339)
+		n.annotateRetention(ctx, ns, newRetention)
+		n.logger.Info("namespace", ns.Name, "deletion postponed", "for", newRetention)
+		n.notifyPolicyEvent(ctx, ns, "DeletionPostponed", "Helm deploy postponed the deletion to "+newRetention)
+		postponed++
 	}
-	return nil
+	return postponed, nil
+}
+
+// postponedDeletion returns the deletion timestamp considering the latest Helm deploy
+// and whether it is later than the current one.
+func (n *NsInformer) postponedDeletion(
+	ns *corev1.Namespace,
+	nsReleases []*release.Release,
+) (time.Time, *release.Release, bool) {
+	nsDeletionTs, _ := n.getNsDeletionTimespamp(ns)
+	latestRelease := n.latestDeployedRelease(nsReleases)
+
+	latestDeployTs := latestRelease.Info.LastDeployed.UTC().Time
+	considerDeletionTs := latestDeployTs.Add(n.policyFor(ns).Retention)
+
+	truncatedNsDeletionTs := nsDeletionTs.Truncate(time.Second)
+	truncatedConsiderDeletionTs := considerDeletionTs.Truncate(time.Second)
+
+	if truncatedNsDeletionTs.Equal(truncatedConsiderDeletionTs) {
+		return nsDeletionTs, latestRelease, false
+	}
+	return considerDeletionTs, latestRelease, nsDeletionTs.Before(considerDeletionTs)
 }
 
 func (n *NsInformer) latestDeployedRelease(releases []*release.Release) *release.Release {
@@ -408,10 +656,11 @@ func (n *NsInformer) filterExpiredNamespaces(
 	for _, ns := range watchedNamespaces {
 		nsDeletionTimespamp, err := n.getNsDeletionTimespamp(ns)
 		if err != nil {
-			n.logger.Error("Invalid timestamp parsed from watched namespace")
-			return expiredNamespaces
+			n.logger.Error("Invalid timestamp parsed from watched namespace", "namespace", ns.Name)
+			continue
 		}
-		if nsDeletionTimespamp.Before(timeNow) {
+		// The deletion ticker runs when any window is open, the namespace waits for its own one.
+		if nsDeletionTimespamp.Before(timeNow) && n.isInWindow(n.policyFor(ns).DeletionWindow, timeNow) {
 			expiredNamespaces = append(expiredNamespaces, ns)
 		}
 	}
@@ -419,11 +668,12 @@ func (n *NsInformer) filterExpiredNamespaces(
 	return expiredNamespaces
 }
 
+// getNsDeletionTimespamp reads the deletion timestamp with any offset the admission webhook accepts, in UTC.
 func (n *NsInformer) getNsDeletionTimespamp(namespace *corev1.Namespace) (time.Time, error) {
-	timeStampAnnotation := namespace.Annotations[n.appConfig.AnnotationKey]
-	nsDeletionTimespamp, err := time.Parse(RFC3339local, timeStampAnnotation)
+	timeStampAnnotation := namespace.Annotations[n.config().AnnotationKey]
+	nsDeletionTimespamp, err := time.Parse(time.RFC3339, timeStampAnnotation)
 
-	return nsDeletionTimespamp, err
+	return nsDeletionTimespamp.UTC(), err
 }
 
 func (n *NsInformer) getNsCreationTimestamp(ns *corev1.Namespace) time.Time {
@@ -434,32 +684,22 @@ func (n *NsInformer) getNsAnnotations(ns *corev1.Namespace) map[string]string {
 	return ns.ObjectMeta.Annotations
 }
 
-func (n *NsInformer) shiftTimeStampByRetention(timestamp time.Time) time.Time {
-	retentionDays := n.appConfig.RetentionDays
-	retentionHours := n.appConfig.RetentionHours
-
-	timeoutDays := time.Duration(retentionDays)
-	shiftedTs := timestamp.Add(time.Hour * 24 * timeoutDays)
-
-	if retentionHours > 0 {
-		timeoutHours := time.Duration(retentionHours)
-		shiftedTs = shiftedTs.Add(time.Hour * timeoutHours)
-	}
-
-	return shiftedTs
+func (n *NsInformer) shiftTimeStampByRetention(ns *corev1.Namespace, timestamp time.Time) time.Time {
+	return timestamp.Add(n.policyFor(ns).Retention)
 }
 
 func (n *NsInformer) processExpiredNamespaces(
 	ctx context.Context,
 	namespaces []*corev1.Namespace,
-) error {
-	batchSize := n.appConfig.DeletionBatchSize
-	napSeconds := time.Duration(n.appConfig.DeletionNapSeconds) * time.Second
+) (int, error) {
+	batchSize := n.config().DeletionBatchSize
+	nap := n.config().DeletionNap
 
 	if batchSize == 0 {
 		batchSize = len(namespaces)
 	}
 
+	deletedCount := 0
 	for i := 0; i < len(namespaces); i += batchSize {
 		batchTail := i + batchSize
 		if batchTail > len(namespaces) {
@@ -468,25 +708,36 @@ func (n *NsInformer) processExpiredNamespaces(
 		batch := namespaces[i:batchTail]
 
 		// Process the batch of namespaces
-		err := n.deleteNamespaces(ctx, batch)
+		deleted, err := n.deleteNamespaces(ctx, batch)
+		deletedCount += deleted
 		if err != nil {
 			n.logger.Error("Could not delete namespaces", err)
-			return err
+			return deletedCount, err
+		}
+
+		if n.config().OrphanCleanup.Enabled {
+			n.cleanupOrphans(ctx, batch)
 		}
 
-		time.Sleep(napSeconds)
+		if n.throttle != nil {
+			n.throttleAfterBatch(ctx, batch)
+		} else {
+			time.Sleep(nap)
+		}
 	}
 
-	return nil
+	return deletedCount, nil
 }
 
-func (n *NsInformer) deleteNamespaces(ctx context.Context, namespaces []*corev1.Namespace) error {
-	deleteOptions := metav1.DeleteOptions{}
+// deleteNamespaces returns the number of deleted namespaces, in dry-run mode the ones it wants to delete.
+func (n *NsInformer) deleteNamespaces(ctx context.Context, namespaces []*corev1.Namespace) (int, error) {
+	deleteOptions := n.deleteOptions()
+	deleted := 0
 
 	for _, ns := range namespaces {
 
-		if n.appConfig.IsUninstallReleases {
-			if n.appConfig.DryRun {
+		if n.policyFor(ns).UninstallReleases {
+			if n.config().DryRun {
 				n.logger.Info("[DRY-RUN] want to uininstall releases from", "namespace", ns.Name)
 			} else {
 				releases, err := n.listNamespaceReleases(ns)
@@ -497,22 +748,38 @@ func (n *NsInformer) deleteNamespaces(ctx context.Context, namespaces []*corev1.
 			}
 		}
 
-		if n.appConfig.DryRun {
+		if n.config().DryRun {
+			if len(n.config().Teardown.Stages) > 0 {
+				n.logger.Info("[DRY-RUN] want to tear down", "namespace", ns.Name, "Stages", n.config().Teardown.Stages)
+			}
 			n.logger.Info("[DRY-RUN] want to delete", "namespace", ns.Name)
+			deleted++
 			continue
 		} else {
+			if n.backupStore != nil {
+				if err := n.backupNamespace(ctx, ns); err != nil {
+					// Namespace is kept until it is backed up, the deletion will be retried in the next iteration.
+					n.logger.Error("Could not backup, skipping deletion", "namespace", ns.Name, "ERROR:", err)
+					continue
+				}
+			}
+			if err := n.teardownNamespace(ctx, ns); err != nil {
+				n.logger.Error("Could not tear down, deleting namespace as is", "namespace", ns.Name, "ERROR:", err)
+			}
 			err := n.client.CoreV1().Namespaces().Delete(ctx, ns.Name, deleteOptions)
 			if err != nil {
 				// If the namespace is already deleted, return without error.
 				if apierrors.IsNotFound(err) {
-					return nil
+					return deleted, nil
 				}
-				return err
+				return deleted, err
 			}
+			n.trackTermination(ns.Name)
 			n.logger.Info("Namespace", ns.Name, "Deleted.")
+			deleted++
 		}
 	}
-	return nil
+	return deleted, nil
 }
 
 func (n *NsInformer) listNamespaceReleases(
@@ -576,5 +843,3 @@ func (n *NsInformer) deleteNamespaceReleases(
 
 	return nil
 }
-
-
@@ -0,0 +1,80 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/logs"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newTestConfig is the config of a loaded config file with the defaults, watching the "review-" namespaces.
+func newTestConfig() utils.Config {
+	config := utils.Config{
+		NsNameDeletionRegexp:         "^review-",
+		DeletionRegexp:               regexp.MustCompile("^review-"),
+		Retention:                    7 * 24 * time.Hour,
+		AnnotationKey:                "delete_after",
+		NsPreserveAnnotation:         utils.NsPreserveAnnotation,
+		NsProtectedUntilAnnotation:   utils.NsProtectedUntilAnnotation,
+		NsProtectionReasonAnnotation: utils.NsProtectionReasonAnnotation,
+		NsSourceRefAnnotation:        utils.NsSourceRefAnnotation,
+		NsExpiredByAnnotation:        utils.NsExpiredByAnnotation,
+		NsWakeAnnotation:             utils.NsWakeAnnotation,
+		NsQuarantineAnnotation:       utils.NsQuarantineAnnotation,
+		LogLevel:                     "ERROR",
+	}
+	config.DeletionWindow.NotBefore = "00:00"
+	config.DeletionWindow.NotAfter = "23:59"
+	config.DeletionWindow.WeekDays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	config.Webhook.BranchKey = utils.NsSourceRefAnnotation
+	config.Webhook.PullRequestKey = "review-reaper/pull-request"
+	return config
+}
+
+// newTestInformer returns the informer over a fake clientset holding the namespaces. The lister
+// is filled once, so tests check the changes through the clientset.
+func newTestInformer(t *testing.T, config utils.Config, namespaces ...*corev1.Namespace) (*NsInformer, *fake.Clientset) {
+	t.Helper()
+	client := fake.NewSimpleClientset()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		if _, err := client.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := indexer.Add(ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := NewNsInformer(nil, client, logs.NewLogger(config), config)
+	n.nsLister = listers.NewNamespaceLister(indexer)
+	return n, client
+}
+
+func newTestNamespace(name string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Annotations:       annotations,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+	}
+}
+
+// getTestNamespace reads the namespace back from the fake clientset.
+func getTestNamespace(t *testing.T, client *fake.Clientset, name string) *corev1.Namespace {
+	t.Helper()
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ns
+}
@@ -0,0 +1,284 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	NS_STATUS_ACTIVE      = "active"
+	NS_STATUS_HIBERNATED  = "hibernated"
+	NS_STATUS_EXPIRED     = "expired"
+	NS_STATUS_QUARANTINED = "quarantined"
+	NS_STATUS_TERMINATING = "terminating"
+	NS_STATUS_PROTECTED   = "protected"
+	NS_STATUS_UNWATCHED   = "unwatched"
+)
+
+// NsPolicy is the part of the config which decides the namespace fate.
+type NsPolicy struct {
+	Name                 string `json:"name,omitempty"`
+	Retention            string `json:"retention"`
+	PostponeByHelmDeploy bool   `json:"postponeByHelmDeploy"`
+	UninstallReleases    bool   `json:"uninstallReleases"`
+}
+
+// NsProtection is set on protected namespaces, Until is empty for a permanent protection.
+type NsProtection struct {
+	Reason string `json:"reason,omitempty"`
+	Until  string `json:"until,omitempty"`
+}
+
+// NsStatus is what the reaper knows about a namespace, it is shared by the admin API and kubectl-reaper.
+type NsStatus struct {
+	Name        string        `json:"name"`
+	Owner       string        `json:"owner,omitempty"`
+	Policy      NsPolicy      `json:"policy"`
+	DeleteAfter string        `json:"deleteAfter,omitempty"`
+	ExpiredBy   string        `json:"expiredBy,omitempty"`
+	Status      string        `json:"status"`
+	Protection  *NsProtection `json:"protection,omitempty"`
+}
+
+// NsExplanation tells why the namespace is or is not watched and when it is going to be deleted.
+type NsExplanation struct {
+	NsStatus
+	Watched           bool     `json:"watched"`
+	EstimatedDeletion string   `json:"estimatedDeletion,omitempty"`
+	Reasons           []string `json:"reasons"`
+}
+
+// NsStatuses returns statuses of the namespaces matching the deletion regexp or selected by a policy,
+// including protected ones.
+func (n *NsInformer) NsStatuses(namespaces []*corev1.Namespace) []NsStatus {
+	statuses := make([]NsStatus, 0)
+	for _, ns := range namespaces {
+		if n.isSelected(ns) {
+			statuses = append(statuses, n.NsStatus(ns))
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (n *NsInformer) NsStatus(ns *corev1.Namespace) NsStatus {
+	policy := n.policyFor(ns)
+	status := NsStatus{
+		Name:  ns.Name,
+		Owner: nsMetaValue(ns, n.config().AdminAPI.OwnerKey),
+		Policy: NsPolicy{
+			Name:                 policy.Name,
+			Retention:            utils.FormatDuration(policy.Retention),
+			PostponeByHelmDeploy: policy.PostponeDeletion,
+			UninstallReleases:    policy.UninstallReleases,
+		},
+		DeleteAfter: ns.Annotations[n.config().AnnotationKey],
+		ExpiredBy:   ns.Annotations[n.config().NsExpiredByAnnotation],
+		Status:      n.nsStatusName(ns),
+	}
+	if n.isProtected(ns) {
+		status.Protection = &NsProtection{
+			Reason: ns.Annotations[n.config().NsProtectionReasonAnnotation],
+			Until:  ns.Annotations[n.config().NsProtectedUntilAnnotation],
+		}
+	}
+	return status
+}
+
+func (n *NsInformer) nsStatusName(ns *corev1.Namespace) string {
+	if ns.DeletionTimestamp != nil {
+		return NS_STATUS_TERMINATING
+	}
+	if !n.isSelected(ns) {
+		return NS_STATUS_UNWATCHED
+	}
+	if !n.isWatched(ns) {
+		return NS_STATUS_PROTECTED
+	}
+	if _, ok := ns.Annotations[n.config().NsQuarantineAnnotation]; ok {
+		return NS_STATUS_QUARANTINED
+	}
+	if deletionTs, err := n.getNsDeletionTimespamp(ns); err == nil && deletionTs.Before(time.Now().UTC()) {
+		return NS_STATUS_EXPIRED
+	}
+	if _, ok := ns.Annotations[HibernatedAnnotation]; ok {
+		return NS_STATUS_HIBERNATED
+	}
+	return NS_STATUS_ACTIVE
+}
+
+// Explain collects the reasons behind the namespace status in the order the reaper applies them.
+func (n *NsInformer) Explain(ns *corev1.Namespace) NsExplanation {
+	explanation := NsExplanation{
+		NsStatus: n.NsStatus(ns),
+		Watched:  n.isWatched(ns),
+		Reasons:  make([]string, 0),
+	}
+	reason := func(format string, args ...interface{}) {
+		explanation.Reasons = append(explanation.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	if !n.isSelected(ns) {
+		reason(
+			"name does not match NsNameDeletionRegexp %q and no ReaperPolicy selects it, the namespace is not watched",
+			n.config().NsNameDeletionRegexp,
+		)
+		return explanation
+	}
+	policy := n.policyFor(ns)
+	if policy.Name != "" {
+		reason("selected by ReaperPolicy %s, its settings override the config", policy.Name)
+	} else if n.config().DeletionRegexp.MatchString(ns.Name) {
+		reason("name matches NsNameDeletionRegexp %q", n.config().NsNameDeletionRegexp)
+	}
+	if claim := n.namespaceClaim(ns); claim != nil {
+		reason(
+			"claimed by ReaperClaim %s with ttl %q, owner %q, contact %q",
+			claim.Name,
+			claim.Spec.TTL,
+			claim.Spec.Owner,
+			claim.Spec.Contact,
+		)
+	}
+
+	protection := n.protectionOf(ns)
+	if !explanation.Watched {
+		switch {
+		case protection.UntilErr != nil:
+			reason("protected, %s", protection.UntilErr)
+		case !protection.Until.IsZero():
+			reason("protected until %s", protection.Until.UTC().Format(time.RFC3339))
+		default:
+			reason("protected by the %s annotation", n.config().NsPreserveAnnotation)
+		}
+		if protection.Reason == "" {
+			reason("no protection reason is given in the %s annotation", n.config().NsProtectionReasonAnnotation)
+		} else {
+			reason("protection reason: %s", protection.Reason)
+		}
+		return explanation
+	}
+	if protection.isExpired(time.Now().UTC()) {
+		reason("protection expired at %s and is going to be lifted", protection.Until.UTC().Format(time.RFC3339))
+	}
+	if ns.DeletionTimestamp != nil {
+		reason("terminating since %s", ns.DeletionTimestamp.UTC().Format(time.RFC3339))
+		return explanation
+	}
+
+	deleteAfter, err := n.getNsDeletionTimespamp(ns)
+	if err != nil {
+		deleteAfter = n.shiftTimeStampByRetention(ns, n.getNsCreationTimestamp(ns)).UTC()
+		reason(
+			"not annotated yet, retention of %s from creation gives %s",
+			utils.FormatDuration(policy.Retention),
+			deleteAfter.Format(time.RFC3339),
+		)
+	} else {
+		reason("%s annotation is %s", n.config().AnnotationKey, deleteAfter.Format(time.RFC3339))
+	}
+	graceEnd := time.Now().UTC().Add(PROTECTION_GRACE_PERIOD)
+	if protection.isExpired(time.Now().UTC()) && deleteAfter.Before(graceEnd) {
+		deleteAfter = graceEnd
+		reason(
+			"lifting the protection moves the deletion to %s, after the %s grace period",
+			deleteAfter.Format(time.RFC3339),
+			utils.FormatDuration(PROTECTION_GRACE_PERIOD),
+		)
+	}
+
+	if expiredBy := ns.Annotations[n.config().NsExpiredByAnnotation]; expiredBy != "" {
+		reason("expired by %s, Helm deploys do not postpone it", expiredBy)
+	} else if policy.PostponeDeletion {
+		reason(
+			"Helm deploys postpone the deletion to %s after the latest deploy",
+			utils.FormatDuration(policy.Retention),
+		)
+	}
+	if _, ok := ns.Annotations[HibernatedAnnotation]; ok {
+		reason("hibernated outside of working hours")
+	}
+
+	dueAt := deleteAfter
+	if n.config().Quarantine.Enabled {
+		quarantinePeriod := n.config().Quarantine.Duration
+		quarantinedAt, err := time.Parse(time.RFC3339, ns.Annotations[n.config().NsQuarantineAnnotation])
+		if err == nil {
+			dueAt = quarantinedAt.Add(quarantinePeriod)
+			reason("quarantined at %s for %s", quarantinedAt.Format(time.RFC3339), utils.FormatDuration(quarantinePeriod))
+		} else {
+			dueAt = n.nextWindowStartIn(policy.DeletionWindow, laterOf(deleteAfter, time.Now().UTC())).Add(quarantinePeriod)
+			reason("is quarantined for %s in the first maintenance window after expiry", utils.FormatDuration(quarantinePeriod))
+		}
+	}
+
+	estimated := n.nextWindowStartIn(policy.DeletionWindow, laterOf(dueAt, time.Now().UTC()))
+	explanation.EstimatedDeletion = estimated.Format(time.RFC3339)
+	reason(
+		"deleted in the first maintenance window after that: %s %s-%s UTC",
+		strings.Join(policy.DeletionWindow.WeekDays, ","),
+		policy.DeletionWindow.NotBefore,
+		policy.DeletionWindow.NotAfter,
+	)
+	return explanation
+}
+
+// Extend moves the deletion timestamp forward from the current one, or from now if it is
+// already in the past. The expiry reason and the quarantine are dropped, as the operator wants
+// to keep the namespace, and the next maintenance iteration restores a quarantined one.
+func (n *NsInformer) Extend(ctx context.Context, ns *corev1.Namespace, duration time.Duration) error {
+	base := time.Now().UTC()
+	if deletionTs, err := n.getNsDeletionTimespamp(ns); err == nil && deletionTs.After(base) {
+		base = deletionTs
+	}
+
+	err := n.annotateRetention(ctx, ns, base.Add(duration).Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	return n.removeNsAnnotations(ctx, ns, n.config().NsExpiredByAnnotation, n.config().NsQuarantineAnnotation)
+}
+
+// nextWindowStart returns t if a deletion window of the config or of a policy is open at t,
+// or the start of the earliest next window.
+func (n *NsInformer) nextWindowStart(t time.Time) time.Time {
+	var next time.Time
+	for i, window := range n.deletionWindows() {
+		if start := n.nextWindowStartIn(window, t); i == 0 || start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
+
+// nextWindowStartIn returns t if the window is open at t, or the start of the next window.
+func (n *NsInformer) nextWindowStartIn(window utils.TimeWindow, t time.Time) time.Time {
+	t = t.UTC()
+	if n.isInWindow(window, t) {
+		return t
+	}
+
+	nbCfg, _ := time.Parse(HH_MM, window.NotBefore)
+	for days := 0; days <= 7; days++ {
+		start := time.Date(t.Year(), t.Month(), t.Day()+days, nbCfg.Hour(), nbCfg.Minute(), 0, 0, time.UTC)
+		if start.After(t) && n.isTodayAllowed(window, start) {
+			return start
+		}
+	}
+	return t
+}
+
+func laterOf(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
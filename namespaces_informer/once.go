@@ -0,0 +1,122 @@
+package namespaces_informer
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	ONCE_CACHE_POLL_INTERVAL = 100 * time.Millisecond
+	ONCE_CACHE_TIMEOUT       = 30 * time.Second
+)
+
+// RunSummary is the outcome of a maintenance iteration, in dry-run mode Deleted counts
+// namespaces which would be deleted.
+type RunSummary struct {
+	Watched     int
+	Unprotected int
+	Annotated   int
+	Postponed   int
+	Expired     int
+	Deleted     int
+	InWindow    bool
+}
+
+// RunOnce performs a single reconciliation for CronJob-like execution: it annotates watched namespaces
+// and, if the maintenance window is open, postpones and deletes them the same way the deletion ticker does.
+// Hibernation, budget, termination, claims and policy statuses are reconciled once instead of by their tickers.
+// Webhooks, the admin API and the admission webhook serve requests and are rejected by the config validation.
+func (n *NsInformer) RunOnce(ctx context.Context) (RunSummary, error) {
+	summary := RunSummary{}
+	if err := n.setup(ctx, nil); err != nil {
+		return summary, err
+	}
+
+	// Namespaces with expired protections are already watched, the cache does not need to catch up.
+	summary.Unprotected = n.liftExpiredProtections(ctx)
+
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		return summary, err
+	}
+	summary.Watched = len(watchedNamespaces)
+
+	annotated := make([]string, 0)
+	for _, ns := range watchedNamespaces {
+		if _, ok := ns.Annotations[n.config().AnnotationKey]; ok {
+			continue
+		}
+		if err := n.ensureAnnotated(ctx, ns); err != nil {
+			continue
+		}
+		if n.config().Quota.Enabled {
+			n.enforceQuota(ctx, ns)
+		}
+		annotated = append(annotated, ns.Name)
+	}
+	summary.Annotated = len(annotated)
+	n.waitForAnnotations(ctx, annotated)
+
+	n.reconcileOnce(ctx)
+
+	if !n.isNowAllowed() {
+		// The maintenance iteration restores them otherwise.
+		if n.config().Quarantine.Enabled {
+			n.restoreLiftedQuarantines(ctx)
+		}
+		n.logger.Info("Maintenance window is closed, skipping deletion", "NextWindow", n.nextWindowStart(time.Now().UTC()))
+		return summary, nil
+	}
+
+	n.logger.Info("Beginning one-shot maintenance iteration")
+	iteration, err := n.maintenanceIteration(ctx)
+	iteration.Unprotected = summary.Unprotected
+	iteration.Annotated = summary.Annotated
+	return iteration, err
+}
+
+// reconcileOnce runs the reconcilers of the enabled features which the tickers run otherwise.
+func (n *NsInformer) reconcileOnce(ctx context.Context) {
+	if n.config().Claims.Enabled {
+		n.syncClaims(ctx)
+	}
+	if n.config().Policies.Enabled {
+		n.syncPolicyStatuses(ctx)
+	}
+	if n.config().Hibernation.Enabled {
+		n.reconcileHibernation(ctx)
+	}
+	if n.config().Budget.Enabled {
+		n.enforceBudget(ctx)
+	}
+	if n.config().TerminationWatch.Enabled {
+		n.checkTermination(ctx)
+	}
+}
+
+// waitForAnnotations waits for the informer cache to catch up with the deletion timestamps written
+// in this run, otherwise the reconcilers would update the namespaces from their stale copies.
+func (n *NsInformer) waitForAnnotations(ctx context.Context, names []string) {
+	err := wait.PollImmediateWithContext(
+		ctx,
+		ONCE_CACHE_POLL_INTERVAL,
+		ONCE_CACHE_TIMEOUT,
+		func(ctx context.Context) (bool, error) {
+			for _, name := range names {
+				ns, err := n.nsLister.Get(name)
+				if err != nil {
+					return false, nil
+				}
+				if _, ok := ns.Annotations[n.config().AnnotationKey]; !ok {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+	)
+	if err != nil {
+		n.logger.Warn("Informer cache has not caught up with the annotations", "Namespaces", names, "ERROR:", err)
+	}
+}
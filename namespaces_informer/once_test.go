@@ -0,0 +1,54 @@
+package namespaces_informer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// TestRunOnceReconciles runs the level-based reconcilers with the deletion window closed,
+// so nothing is deleted and the hibernation is done by the one-shot run alone.
+func TestRunOnceReconciles(t *testing.T) {
+	config := newHibernationTestConfig(false)
+	config.RunOnce = true
+	config.DeletionWindow.WeekDays = nil
+	n, client := newTestInformer(
+		t,
+		config,
+		newTestNamespace("review-annotated", map[string]string{
+			"delete_after": time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+		}),
+		newTestNamespace("review-new", nil),
+		newTestNamespace("production", nil),
+	)
+	n.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	createTestDeployment(t, client, "review-annotated", 2, nil)
+
+	summary, err := n.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Watched != 2 || summary.Annotated != 1 || summary.Deleted != 0 {
+		t.Errorf("expected 2 watched, 1 annotated and nothing deleted, got %+v", summary)
+	}
+	// Hibernation runs after the cache has caught up, so it keeps the deletion timestamp.
+	annotations := getTestNamespace(t, client, "review-new").Annotations
+	if _, ok := annotations["delete_after"]; !ok {
+		t.Error("expected review-new to be annotated")
+	}
+	if _, ok := annotations[HibernatedAnnotation]; !ok {
+		t.Error("expected review-new to be hibernated")
+	}
+
+	ns := getTestNamespace(t, client, "review-annotated")
+	if _, ok := ns.Annotations[HibernatedAnnotation]; !ok {
+		t.Error("expected review-annotated to be hibernated outside working hours")
+	}
+	hibernated := getTestDeployment(t, client, "review-annotated")
+	if *hibernated.Spec.Replicas != 0 || hibernated.Annotations[ReplicasAnnotation] != "2" {
+		t.Errorf("expected the deployment scaled down from 2, got %d %v", *hibernated.Spec.Replicas, hibernated.Annotations)
+	}
+}
@@ -0,0 +1,300 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"errors"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ORPHANS_MODE_DELETE = "delete"
+	ORPHANS_MODE_REPORT = "report"
+)
+
+// orphan is a cluster-scoped object which outlives a deleted review namespace.
+type orphan struct {
+	kind   string
+	name   string
+	reason string
+	delete func(ctx context.Context) error
+}
+
+// cleanupOrphans is the phase after deleteNamespaces: it finds cluster-scoped leftovers
+// of deleted namespaces and deletes or reports them, depending on the configured mode.
+func (n *NsInformer) cleanupOrphans(ctx context.Context, namespaces []*corev1.Namespace) {
+	deletedNames := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if n.config().DryRun || n.isNsGone(ctx, ns.Name) {
+			deletedNames = append(deletedNames, ns.Name)
+		}
+	}
+	if len(deletedNames) == 0 {
+		return
+	}
+
+	orphans, err := n.findOrphans(ctx, deletedNames)
+	if err != nil {
+		n.logger.Error("Could not look up orphaned cluster-scoped objects", "ERROR:", err)
+	}
+
+	for _, o := range orphans {
+		switch {
+		case n.config().DryRun:
+			n.logger.Info("[DRY-RUN] want to delete orphan", "Kind", o.kind, "Name", o.name, "Reason", o.reason)
+		case n.config().OrphanCleanup.Mode == ORPHANS_MODE_REPORT:
+			n.logger.Warn("Found orphan", "Kind", o.kind, "Name", o.name, "Reason", o.reason)
+		default:
+			if err := o.delete(ctx); err != nil && !apierrors.IsNotFound(err) {
+				n.logger.Error("Could not delete orphan", "Kind", o.kind, "Name", o.name, "ERROR:", err)
+				continue
+			}
+			n.logger.Info("Orphan deleted", "Kind", o.kind, "Name", o.name, "Reason", o.reason)
+		}
+	}
+}
+
+// isNsGone reports whether the namespace is deleted or is being deleted.
+func (n *NsInformer) isNsGone(ctx context.Context, name string) bool {
+	ns, err := n.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	return err == nil && ns.DeletionTimestamp != nil
+}
+
+// findOrphans runs all finders, a failing one (e.g. for missing RBAC) does not hide
+// the orphans found by the others, so its error is returned along with them.
+func (n *NsInformer) findOrphans(ctx context.Context, namespaces []string) ([]orphan, error) {
+	orphans := make([]orphan, 0)
+	errs := make([]error, 0)
+	finders := []func(context.Context, []string) ([]orphan, error){
+		n.findOrphanedVolumes,
+		n.findOrphanedClusterRoleBindings,
+		n.findOrphanedWebhookConfigurations,
+		n.findLabeledClusterRoles,
+	}
+
+	for _, find := range finders {
+		found, err := find(ctx, namespaces)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		orphans = append(orphans, found...)
+	}
+	return orphans, errors.Join(errs...)
+}
+
+// isLabeledFor checks the configurable label, which allows to tie any of the supported
+// cluster-scoped objects to a review namespace explicitly.
+func (n *NsInformer) isLabeledFor(meta metav1.ObjectMeta, namespaces []string) bool {
+	key := n.config().OrphanCleanup.NamespaceLabel
+	value, ok := meta.Labels[key]
+	return key != "" && ok && isOneOf(value, namespaces)
+}
+
+func (n *NsInformer) findOrphanedVolumes(ctx context.Context, namespaces []string) ([]orphan, error) {
+	client := n.client.CoreV1().PersistentVolumes()
+	volumes, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]orphan, 0)
+	for _, pv := range volumes.Items {
+		reason := ""
+		switch {
+		case n.isLabeledFor(pv.ObjectMeta, namespaces):
+			reason = "label"
+		case pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain &&
+			pv.Spec.ClaimRef != nil && isOneOf(pv.Spec.ClaimRef.Namespace, namespaces):
+			reason = "claimRef"
+		default:
+			continue
+		}
+
+		name := pv.Name
+		orphans = append(orphans, orphan{
+			kind:   "PersistentVolume",
+			name:   name,
+			reason: reason,
+			delete: func(ctx context.Context) error {
+				return client.Delete(ctx, name, metav1.DeleteOptions{})
+			},
+		})
+	}
+	return orphans, nil
+}
+
+// findOrphanedClusterRoleBindings returns bindings all subjects of which are service accounts
+// (or other namespaced subjects) of the deleted namespaces.
+func (n *NsInformer) findOrphanedClusterRoleBindings(
+	ctx context.Context,
+	namespaces []string,
+) ([]orphan, error) {
+	client := n.client.RbacV1().ClusterRoleBindings()
+	bindings, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]orphan, 0)
+	for _, binding := range bindings.Items {
+		reason := ""
+		if n.isLabeledFor(binding.ObjectMeta, namespaces) {
+			reason = "label"
+		} else if len(binding.Subjects) > 0 {
+			reason = "subject namespace"
+			for _, subject := range binding.Subjects {
+				if !isOneOf(subject.Namespace, namespaces) {
+					reason = ""
+					break
+				}
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		name := binding.Name
+		orphans = append(orphans, orphan{
+			kind:   "ClusterRoleBinding",
+			name:   name,
+			reason: reason,
+			delete: func(ctx context.Context) error {
+				return client.Delete(ctx, name, metav1.DeleteOptions{})
+			},
+		})
+	}
+	return orphans, nil
+}
+
+// findOrphanedWebhookConfigurations returns admission webhook configurations all webhooks
+// of which call services in the deleted namespaces.
+func (n *NsInformer) findOrphanedWebhookConfigurations(
+	ctx context.Context,
+	namespaces []string,
+) ([]orphan, error) {
+	orphans := make([]orphan, 0)
+
+	validatingClient := n.client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	validating, err := validatingClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, configuration := range validating.Items {
+		serviceNamespaces := make([]string, 0, len(configuration.Webhooks))
+		for _, webhook := range configuration.Webhooks {
+			serviceNamespaces = append(serviceNamespaces, webhookServiceNamespace(webhook.ClientConfig.Service))
+		}
+		reason := n.webhookOrphanReason(configuration.ObjectMeta, serviceNamespaces, namespaces)
+		if reason == "" {
+			continue
+		}
+
+		name := configuration.Name
+		orphans = append(orphans, orphan{
+			kind:   "ValidatingWebhookConfiguration",
+			name:   name,
+			reason: reason,
+			delete: func(ctx context.Context) error {
+				return validatingClient.Delete(ctx, name, metav1.DeleteOptions{})
+			},
+		})
+	}
+
+	mutatingClient := n.client.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	mutating, err := mutatingClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, configuration := range mutating.Items {
+		serviceNamespaces := make([]string, 0, len(configuration.Webhooks))
+		for _, webhook := range configuration.Webhooks {
+			serviceNamespaces = append(serviceNamespaces, webhookServiceNamespace(webhook.ClientConfig.Service))
+		}
+		reason := n.webhookOrphanReason(configuration.ObjectMeta, serviceNamespaces, namespaces)
+		if reason == "" {
+			continue
+		}
+
+		name := configuration.Name
+		orphans = append(orphans, orphan{
+			kind:   "MutatingWebhookConfiguration",
+			name:   name,
+			reason: reason,
+			delete: func(ctx context.Context) error {
+				return mutatingClient.Delete(ctx, name, metav1.DeleteOptions{})
+			},
+		})
+	}
+
+	return orphans, nil
+}
+
+func (n *NsInformer) webhookOrphanReason(
+	meta metav1.ObjectMeta,
+	serviceNamespaces []string,
+	namespaces []string,
+) string {
+	if n.isLabeledFor(meta, namespaces) {
+		return "label"
+	}
+	if len(serviceNamespaces) == 0 {
+		return ""
+	}
+	for _, serviceNamespace := range serviceNamespaces {
+		if !isOneOf(serviceNamespace, namespaces) {
+			return ""
+		}
+	}
+	return "service namespace"
+}
+
+// findLabeledClusterRoles returns cluster roles explicitly tied to the deleted namespaces,
+// there is no other reliable way to tell that a cluster role belongs to a namespace.
+func (n *NsInformer) findLabeledClusterRoles(ctx context.Context, namespaces []string) ([]orphan, error) {
+	if n.config().OrphanCleanup.NamespaceLabel == "" {
+		return nil, nil
+	}
+
+	client := n.client.RbacV1().ClusterRoles()
+	roles, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]orphan, 0)
+	for _, role := range roles.Items {
+		if !n.isLabeledFor(role.ObjectMeta, namespaces) {
+			continue
+		}
+
+		name := role.Name
+		orphans = append(orphans, orphan{
+			kind:   "ClusterRole",
+			name:   name,
+			reason: "label",
+			delete: func(ctx context.Context) error {
+				return client.Delete(ctx, name, metav1.DeleteOptions{})
+			},
+		})
+	}
+	return orphans, nil
+}
+
+func isOneOf(value string, values []string) bool {
+	return value != "" && utils.IsContains(values, value)
+}
+
+func webhookServiceNamespace(service *admissionregistrationv1.ServiceReference) string {
+	if service == nil {
+		return ""
+	}
+	return service.Namespace
+}
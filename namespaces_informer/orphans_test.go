@@ -0,0 +1,40 @@
+package namespaces_informer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestFindOrphansContinuesAfterFailure(t *testing.T) {
+	n, client := newTestInformer(t, newTestConfig())
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "review-a-admin"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "default", Namespace: "review-a"}},
+	}
+	if _, err := client.RbacV1().ClusterRoleBindings().Create(context.Background(), binding, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	errForbidden := errors.New("persistentvolumes is forbidden")
+	client.PrependReactor("list", "persistentvolumes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errForbidden
+	})
+	errUnavailable := errors.New("mutatingwebhookconfigurations is unavailable")
+	client.PrependReactor("list", "mutatingwebhookconfigurations", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errUnavailable
+	})
+
+	orphans, err := n.findOrphans(context.Background(), []string{"review-a"})
+	if !errors.Is(err, errForbidden) || !errors.Is(err, errUnavailable) {
+		t.Errorf("expected both finder errors, got %v", err)
+	}
+	if len(orphans) != 1 || orphans[0].kind != "ClusterRoleBinding" || orphans[0].name != "review-a-admin" {
+		t.Errorf("expected the orphaned binding to be found anyway, got %+v", orphans)
+	}
+}
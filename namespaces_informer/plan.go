@@ -0,0 +1,153 @@
+package namespaces_informer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// MaintenancePlan is what the next maintenance iteration is going to do with the namespaces
+// as they are now. It is built once, without starting the informer or tickers.
+type MaintenancePlan struct {
+	GeneratedAt time.Time
+	NextWindow  time.Time
+	InWindow    bool
+	Annotate    []PlannedAnnotation
+	Postpone    []PlannedPostponement
+	Quarantine  []string
+	Batches     [][]string
+}
+
+type PlannedAnnotation struct {
+	Namespace   string
+	DeleteAfter time.Time
+}
+
+type PlannedPostponement struct {
+	Namespace string
+	From      string
+	To        time.Time
+	Release   string
+}
+
+// Plan evaluates the namespaces the same way the deletion ticker does, at the start of the next
+// maintenance window (or now, if the window is open). Forge sync is not evaluated,
+// only namespaces it has already expired are.
+func (n *NsInformer) Plan(namespaces []*corev1.Namespace) MaintenancePlan {
+	timeNow := time.Now().UTC()
+	plan := MaintenancePlan{
+		GeneratedAt: timeNow,
+		NextWindow:  n.nextWindowStart(timeNow),
+		InWindow:    n.isNowAllowed(),
+	}
+
+	expired := make([]*corev1.Namespace, 0)
+	for _, ns := range n.filterWatchedNamespaces(namespaces) {
+		if ns.DeletionTimestamp != nil {
+			continue
+		}
+
+		policy := n.policyFor(ns)
+		deleteAfter, err := n.getNsDeletionTimespamp(ns)
+		if _, ok := ns.Annotations[n.config().AnnotationKey]; !ok || err != nil {
+			deleteAfter = n.shiftTimeStampByRetention(ns, n.getNsCreationTimestamp(ns)).UTC()
+			plan.Annotate = append(plan.Annotate, PlannedAnnotation{Namespace: ns.Name, DeleteAfter: deleteAfter})
+		} else if _, ok := ns.Annotations[n.config().NsExpiredByAnnotation]; !ok && policy.PostponeDeletion {
+			nsReleases, _ := n.listNamespaceReleases(ns)
+			if len(nsReleases) > 0 {
+				postponedTs, latestRelease, isPostponed := n.postponedDeletion(ns, nsReleases)
+				if isPostponed {
+					plan.Postpone = append(plan.Postpone, PlannedPostponement{
+						Namespace: ns.Name,
+						From:      ns.Annotations[n.config().AnnotationKey],
+						To:        postponedTs,
+						Release:   latestRelease.Name,
+					})
+					deleteAfter = postponedTs
+				}
+			}
+		}
+
+		if deleteAfter.Before(plan.NextWindow) && n.isInWindow(policy.DeletionWindow, plan.NextWindow) {
+			expired = append(expired, ns)
+		}
+	}
+
+	if n.config().Quarantine.Enabled {
+		period := n.config().Quarantine.Duration
+		quarantineIsOver := make([]*corev1.Namespace, 0)
+		for _, ns := range expired {
+			quarantinedAt, err := time.Parse(time.RFC3339, ns.Annotations[n.config().NsQuarantineAnnotation])
+			switch {
+			case err != nil:
+				plan.Quarantine = append(plan.Quarantine, ns.Name)
+			case quarantinedAt.Add(period).Before(plan.NextWindow):
+				quarantineIsOver = append(quarantineIsOver, ns)
+			}
+		}
+		expired = quarantineIsOver
+	}
+
+	batchSize := n.config().DeletionBatchSize
+	if batchSize == 0 {
+		batchSize = len(expired)
+	}
+	for i := 0; i < len(expired); i += batchSize {
+		batchTail := i + batchSize
+		if batchTail > len(expired) {
+			batchTail = len(expired)
+		}
+		batch := make([]string, 0, batchTail-i)
+		for _, ns := range expired[i:batchTail] {
+			batch = append(batch, ns.Name)
+		}
+		plan.Batches = append(plan.Batches, batch)
+	}
+
+	return plan
+}
+
+// Print writes the plan as a human readable report.
+func (p MaintenancePlan) Print(w io.Writer) {
+	if p.InWindow {
+		fmt.Fprintln(w, "Maintenance window is open now")
+	} else {
+		fmt.Fprintf(w, "Next maintenance window opens at %s\n", p.NextWindow.Format(time.RFC3339))
+	}
+
+	fmt.Fprintf(w, "\nWould annotate (%d):\n", len(p.Annotate))
+	for _, a := range p.Annotate {
+		fmt.Fprintf(w, "  %s delete after %s\n", a.Namespace, a.DeleteAfter.Format(time.RFC3339))
+	}
+
+	fmt.Fprintf(w, "\nWould postpone (%d):\n", len(p.Postpone))
+	for _, postponement := range p.Postpone {
+		fmt.Fprintf(
+			w,
+			"  %s from %s to %s by Helm release %s\n",
+			postponement.Namespace,
+			postponement.From,
+			postponement.To.Format(time.RFC3339),
+			postponement.Release,
+		)
+	}
+
+	if len(p.Quarantine) > 0 {
+		fmt.Fprintf(w, "\nWould quarantine (%d):\n", len(p.Quarantine))
+		for _, name := range p.Quarantine {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+
+	deleteCount := 0
+	for _, batch := range p.Batches {
+		deleteCount += len(batch)
+	}
+	fmt.Fprintf(w, "\nWould delete (%d):\n", deleteCount)
+	for i, batch := range p.Batches {
+		fmt.Fprintf(w, "  batch %d: %s\n", i+1, strings.Join(batch, ", "))
+	}
+}
@@ -0,0 +1,335 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/policies"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const POLICY_STATUS_INTERVAL = time.Minute
+
+// governingPolicy is what applies to a namespace: the config, overridden by the ReaperPolicy selecting it.
+type governingPolicy struct {
+	Name              string
+	Retention         time.Duration
+	DeletionWindow    utils.TimeWindow
+	PostponeDeletion  bool
+	UninstallReleases bool
+	Events            bool
+}
+
+// compiledPolicy is a ReaperPolicy with parsed selectors and settings, nil settings are taken from the config.
+type compiledPolicy struct {
+	policy         *policies.ReaperPolicy
+	selector       labels.Selector
+	nameRegexp     *regexp.Regexp
+	retention      *time.Duration
+	deletionWindow *utils.TimeWindow
+	err            error
+}
+
+func compilePolicy(policy *policies.ReaperPolicy) *compiledPolicy {
+	compiled := &compiledPolicy{policy: policy}
+	spec := policy.Spec
+	errs := make([]error, 0)
+
+	if spec.NamespaceSelector == nil && spec.NameRegexp == "" {
+		errs = append(errs, errors.New("namespaceSelector or nameRegexp is required"))
+	}
+	if spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid namespaceSelector: %w", err))
+		}
+		compiled.selector = selector
+	}
+	if spec.NameRegexp != "" {
+		nameRegexp, err := regexp.Compile(spec.NameRegexp)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Unable to compile nameRegexp: %w", err))
+		}
+		compiled.nameRegexp = nameRegexp
+	}
+	if spec.Retention != "" {
+		retention, err := utils.ParseDuration(spec.Retention)
+		if err != nil || retention < 0 {
+			errs = append(errs, fmt.Errorf("Invalid retention, expected a non-negative duration like 3d12h, got %q", spec.Retention))
+		}
+		compiled.retention = &retention
+	}
+	if spec.DeletionWindow != nil {
+		window, err := utils.NewTimeWindow(
+			"deletionWindow",
+			spec.DeletionWindow.NotBefore,
+			spec.DeletionWindow.NotAfter,
+			spec.DeletionWindow.WeekDays,
+		)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		compiled.deletionWindow = &window
+	}
+
+	compiled.err = errors.Join(errs...)
+	return compiled
+}
+
+// selects tells whether both selectors of a valid policy match the namespace.
+func (p *compiledPolicy) selects(ns *corev1.Namespace) bool {
+	if p.err != nil {
+		return false
+	}
+	if p.selector != nil && !p.selector.Matches(labels.Set(ns.Labels)) {
+		return false
+	}
+	if p.nameRegexp != nil && !p.nameRegexp.MatchString(ns.Name) {
+		return false
+	}
+	return true
+}
+
+// setupPolicies starts the ReaperPolicy informer, the caller waits for the returned cache sync.
+// Policies are listed first, so a missing CRD fails the start instead of blocking the sync forever.
+func (n *NsInformer) setupPolicies(ctx context.Context, isWatching bool) (cache.InformerSynced, error) {
+	n.policyClient = policies.NewClient(n.dynamicClient)
+	if _, err := n.policyClient.List(ctx); err != nil {
+		return nil, fmt.Errorf("Could not list ReaperPolicies, is the CRD installed? %w", err)
+	}
+
+	policyInformer := policies.NewInformer(n.dynamicClient, RESYNC_TIMEOUT)
+	n.policyLister = policyInformer.Lister()
+	if isWatching {
+		policyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { n.onPolicyChange() },
+			UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+				// Status updates and resyncs do not change the generation.
+				if oldObj.(metav1.Object).GetGeneration() != newObj.(metav1.Object).GetGeneration() {
+					n.onPolicyChange()
+				}
+			},
+			DeleteFunc: func(obj interface{}) { n.onPolicyChange() },
+		})
+	}
+	go policyInformer.Informer().Run(ctx.Done())
+	return policyInformer.Informer().HasSynced, nil
+}
+
+// LoadPolicies lists the policies and claims once, for commands which evaluate namespaces without the informer.
+func (n *NsInformer) LoadPolicies(ctx context.Context) error {
+	if !n.config().Policies.Enabled && !n.config().Claims.Enabled {
+		return nil
+	}
+	if err := n.setupDynamicClient(); err != nil {
+		return err
+	}
+
+	if n.config().Policies.Enabled {
+		list, err := policies.NewClient(n.dynamicClient).List(ctx)
+		if err != nil {
+			return fmt.Errorf("Could not list ReaperPolicies, is the CRD installed? %w", err)
+		}
+		n.setPolicies(list)
+	}
+	if n.config().Claims.Enabled {
+		claimLister, err := policies.NewClaimClient(n.dynamicClient).Snapshot(ctx)
+		if err != nil {
+			return fmt.Errorf("Could not list ReaperClaims, is the CRD installed? %w", err)
+		}
+		n.claimLister = claimLister
+	}
+	return nil
+}
+
+func (n *NsInformer) onPolicyChange() {
+	n.setPolicies(n.policyLister.List())
+	select {
+	case n.policiesChanged <- struct{}{}:
+	default:
+	}
+}
+
+// setPolicies compiles the policies and orders them by priority, the first selecting one governs a namespace.
+// Invalid policies are logged once per generation.
+func (n *NsInformer) setPolicies(list []*policies.ReaperPolicy) {
+	logged := make(map[string]int64)
+	for _, policy := range n.compiledPolicies() {
+		if policy.err != nil {
+			logged[policy.policy.Name] = policy.policy.Generation
+		}
+	}
+
+	compiled := make([]*compiledPolicy, 0, len(list))
+	for _, policy := range list {
+		p := compilePolicy(policy)
+		if generation, ok := logged[policy.Name]; p.err != nil && (!ok || generation != policy.Generation) {
+			n.logger.Warn("Invalid ReaperPolicy, it selects nothing", "Policy", policy.Name, "ERROR:", p.err)
+		}
+		compiled = append(compiled, p)
+	}
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].policy.Spec.Priority > compiled[j].policy.Spec.Priority
+	})
+
+	n.policiesMutex.Lock()
+	n.policies = compiled
+	n.policiesMutex.Unlock()
+}
+
+func (n *NsInformer) compiledPolicies() []*compiledPolicy {
+	n.policiesMutex.RLock()
+	defer n.policiesMutex.RUnlock()
+	return n.policies
+}
+
+func (n *NsInformer) selectingPolicy(ns *corev1.Namespace) *compiledPolicy {
+	for _, policy := range n.compiledPolicies() {
+		if policy.selects(ns) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// policyFor returns the settings governing the namespace: the config, overridden by the ReaperPolicy selecting it,
+// with the retention shortened to the TTL of the ReaperClaim of the namespace.
+func (n *NsInformer) policyFor(ns *corev1.Namespace) governingPolicy {
+	cfg := n.config()
+	governing := governingPolicy{
+		Retention:         cfg.Retention,
+		DeletionWindow:    cfg.DeletionWindow,
+		PostponeDeletion:  cfg.PostponeDeletion,
+		UninstallReleases: cfg.IsUninstallReleases,
+	}
+
+	if policy := n.selectingPolicy(ns); policy != nil {
+		governing.Name = policy.policy.Name
+		if policy.retention != nil {
+			governing.Retention = *policy.retention
+		}
+		if policy.deletionWindow != nil {
+			governing.DeletionWindow = *policy.deletionWindow
+		}
+		if helm := policy.policy.Spec.Helm; helm != nil {
+			if helm.PostponeByDeploy != nil {
+				governing.PostponeDeletion = *helm.PostponeByDeploy
+			}
+			if helm.UninstallReleases != nil {
+				governing.UninstallReleases = *helm.UninstallReleases
+			}
+		}
+		if notifications := policy.policy.Spec.Notifications; notifications != nil {
+			governing.Events = notifications.Events
+		}
+	}
+
+	if claim := n.namespaceClaim(ns); claim != nil {
+		if ttl, _ := claimTTL(claim); ttl > 0 && ttl < governing.Retention {
+			governing.Retention = ttl
+		}
+	}
+	return governing
+}
+
+// deletionWindows are the config window and the windows of the policies, the deletion ticker
+// runs when any of them is open and deletes namespaces whose own window is open.
+func (n *NsInformer) deletionWindows() []utils.TimeWindow {
+	windows := []utils.TimeWindow{n.config().DeletionWindow}
+	for _, policy := range n.compiledPolicies() {
+		if policy.deletionWindow != nil && policy.err == nil {
+			windows = append(windows, *policy.deletionWindow)
+		}
+	}
+	return windows
+}
+
+// notifyPolicyEvent records an Event in the namespace if its policy asks for notifications.
+func (n *NsInformer) notifyPolicyEvent(ctx context.Context, ns *corev1.Namespace, reason string, message string) {
+	policy := n.policyFor(ns)
+	if !policy.Events {
+		return
+	}
+	n.recordNamespaceEvent(ctx, ns, reason, fmt.Sprintf("%s, by ReaperPolicy %s", message, policy.Name))
+}
+
+// PolicyTicker re-evaluates namespaces when policies change and writes policy statuses.
+func (n *NsInformer) PolicyTicker(ctx context.Context) {
+	ticker := time.NewTicker(POLICY_STATUS_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Finishing policy ticker...")
+			return
+		case <-n.policiesChanged:
+			n.reconcileWatched(ctx)
+		case <-ticker.C:
+		}
+		n.syncPolicyStatuses(ctx)
+	}
+}
+
+// syncPolicyStatuses writes the watched namespaces each policy governs and the earliest of their deletions.
+func (n *NsInformer) syncPolicyStatuses(ctx context.Context) {
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		n.logger.Error("Could not list watched namespaces for policy statuses", "ERROR:", err)
+		return
+	}
+
+	// Compiled policies are not updated on status changes, so the current statuses come from the cache.
+	currentStatuses := make(map[string]policies.ReaperPolicyStatus)
+	for _, policy := range n.policyLister.List() {
+		currentStatuses[policy.Name] = policy.Status
+	}
+
+	compiled := n.compiledPolicies()
+	statuses := make(map[string]*policies.ReaperPolicyStatus, len(compiled))
+	nextDeletions := make(map[string]time.Time, len(compiled))
+	for _, policy := range compiled {
+		status := &policies.ReaperPolicyStatus{ObservedGeneration: policy.policy.Generation}
+		if policy.err != nil {
+			status.Error = policy.err.Error()
+		}
+		statuses[policy.policy.Name] = status
+	}
+
+	for _, ns := range watchedNamespaces {
+		policy := n.selectingPolicy(ns)
+		if policy == nil {
+			continue
+		}
+		status := statuses[policy.policy.Name]
+		status.MatchedNamespaces = append(status.MatchedNamespaces, ns.Name)
+
+		deletionTs, err := n.getNsDeletionTimespamp(ns)
+		if err != nil {
+			continue
+		}
+		if next, ok := nextDeletions[policy.policy.Name]; !ok || deletionTs.Before(next) {
+			nextDeletions[policy.policy.Name] = deletionTs
+			status.NextDeletion = deletionTs.Format(time.RFC3339)
+			status.NextDeletionNamespace = ns.Name
+		}
+	}
+
+	for _, policy := range compiled {
+		status := statuses[policy.policy.Name]
+		if reflect.DeepEqual(currentStatuses[policy.policy.Name], *status) {
+			continue
+		}
+		if err := n.policyClient.UpdateStatus(ctx, policy.policy.Name, *status); err != nil {
+			n.logger.Error("Could not update ReaperPolicy status", "Policy", policy.policy.Name, "ERROR:", err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/policies"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestPolicy(name string, priority int, spec policies.ReaperPolicySpec) *policies.ReaperPolicy {
+	spec.Priority = priority
+	return &policies.ReaperPolicy{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+func TestSelectingPolicy(t *testing.T) {
+	teamA := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	ns := newTestNamespace("review-login", nil)
+	ns.Labels = map[string]string{"team": "a"}
+
+	tests := []struct {
+		name     string
+		policies []*policies.ReaperPolicy
+		selected string
+	}{
+		{
+			name:     "no policies",
+			selected: "",
+		},
+		{
+			name: "highest priority wins",
+			policies: []*policies.ReaperPolicy{
+				newTestPolicy("low", 1, policies.ReaperPolicySpec{NameRegexp: "^review-"}),
+				newTestPolicy("high", 10, policies.ReaperPolicySpec{NamespaceSelector: teamA}),
+				newTestPolicy("default", 0, policies.ReaperPolicySpec{NameRegexp: ".*"}),
+			},
+			selected: "high",
+		},
+		{
+			name: "equal priority keeps the listed order",
+			policies: []*policies.ReaperPolicy{
+				newTestPolicy("first", 5, policies.ReaperPolicySpec{NameRegexp: "^review-"}),
+				newTestPolicy("second", 5, policies.ReaperPolicySpec{NamespaceSelector: teamA}),
+			},
+			selected: "first",
+		},
+		{
+			name: "both selectors have to match",
+			policies: []*policies.ReaperPolicy{
+				newTestPolicy("other-name", 10, policies.ReaperPolicySpec{NamespaceSelector: teamA, NameRegexp: "^feature-"}),
+				newTestPolicy("other-team", 10, policies.ReaperPolicySpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}},
+					NameRegexp:        "^review-",
+				}),
+				newTestPolicy("both", 1, policies.ReaperPolicySpec{NamespaceSelector: teamA, NameRegexp: "^review-"}),
+			},
+			selected: "both",
+		},
+		{
+			name: "invalid policy selects nothing",
+			policies: []*policies.ReaperPolicy{
+				newTestPolicy("bad-retention", 10, policies.ReaperPolicySpec{NameRegexp: "^review-", Retention: "soon"}),
+				newTestPolicy("no-selector", 10, policies.ReaperPolicySpec{}),
+				newTestPolicy("valid", 1, policies.ReaperPolicySpec{NameRegexp: "^review-"}),
+			},
+			selected: "valid",
+		},
+		{
+			name: "nothing selects",
+			policies: []*policies.ReaperPolicy{
+				newTestPolicy("feature", 1, policies.ReaperPolicySpec{NameRegexp: "^feature-"}),
+			},
+			selected: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, _ := newTestInformer(t, newTestConfig())
+			n.setPolicies(tt.policies)
+
+			selected := ""
+			if policy := n.selectingPolicy(ns); policy != nil {
+				selected = policy.policy.Name
+			}
+			if selected != tt.selected {
+				t.Errorf("expected policy %q, got %q", tt.selected, selected)
+			}
+		})
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	disabled := false
+	config := newTestConfig()
+	config.IsUninstallReleases = true
+	n, _ := newTestInformer(t, config)
+	n.setPolicies([]*policies.ReaperPolicy{
+		newTestPolicy("short", 1, policies.ReaperPolicySpec{
+			NameRegexp: "^review-short-",
+			Retention:  "1d",
+			Helm:       &policies.HelmOptions{UninstallReleases: &disabled},
+		}),
+		newTestPolicy("window", 1, policies.ReaperPolicySpec{
+			NameRegexp:     "^review-window-",
+			DeletionWindow: &policies.DeletionWindow{NotBefore: "01:00", NotAfter: "02:00"},
+		}),
+	})
+
+	tests := []struct {
+		ns                *corev1.Namespace
+		name              string
+		retention         time.Duration
+		notBefore         string
+		uninstallReleases bool
+	}{
+		{ns: newTestNamespace("review-other", nil), retention: 7 * 24 * time.Hour, notBefore: "00:00", uninstallReleases: true},
+		{ns: newTestNamespace("review-short-1", nil), name: "short", retention: 24 * time.Hour, notBefore: "00:00"},
+		{ns: newTestNamespace("review-window-1", nil), name: "window", retention: 7 * 24 * time.Hour, notBefore: "01:00", uninstallReleases: true},
+	}
+	for _, tt := range tests {
+		policy := n.policyFor(tt.ns)
+		if policy.Name != tt.name || policy.Retention != tt.retention ||
+			policy.DeletionWindow.NotBefore != tt.notBefore || policy.UninstallReleases != tt.uninstallReleases {
+			t.Errorf("%s: unexpected governing policy %+v", tt.ns.Name, policy)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package namespaces_informer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	PROTECTION_TICK = time.Minute
+	// PROTECTION_GRACE_PERIOD is the least time a namespace is kept after its protection expires,
+	// so its owners can extend it before the deletion timestamp, which might be long past, gets it deleted.
+	PROTECTION_GRACE_PERIOD = 24 * time.Hour
+)
+
+var errProtectionReasonMissing = errors.New("protection reason is required")
+
+// nsProtection is the protection of a namespace by its annotations. A namespace is protected by
+// NsPreserveAnnotation with any value, or by NsProtectedUntilAnnotation until the timestamp passes.
+type nsProtection struct {
+	IsSet  bool
+	Reason string
+	// Until is zero for a permanent protection.
+	Until time.Time
+	// UntilErr keeps the namespace protected, a typo should not get it deleted.
+	UntilErr error
+}
+
+func (n *NsInformer) protectionOf(ns *corev1.Namespace) nsProtection {
+	_, isPreserved := ns.Annotations[n.config().NsPreserveAnnotation]
+	until, hasUntil := ns.Annotations[n.config().NsProtectedUntilAnnotation]
+	protection := nsProtection{
+		IsSet:  isPreserved || hasUntil,
+		Reason: ns.Annotations[n.config().NsProtectionReasonAnnotation],
+	}
+	if hasUntil {
+		protection.Until, protection.UntilErr = time.Parse(time.RFC3339, until)
+		if protection.UntilErr != nil {
+			protection.UntilErr = fmt.Errorf("invalid %s %q, expected an RFC3339 timestamp", n.config().NsProtectedUntilAnnotation, until)
+		}
+	}
+	return protection
+}
+
+// isExpired tells whether the protection has run out and is going to be lifted.
+func (p nsProtection) isExpired(now time.Time) bool {
+	return p.IsSet && p.UntilErr == nil && !p.Until.IsZero() && !p.Until.After(now)
+}
+
+func (n *NsInformer) isProtected(ns *corev1.Namespace) bool {
+	protection := n.protectionOf(ns)
+	return protection.IsSet && !protection.isExpired(time.Now().UTC())
+}
+
+// ProtectionTicker periodically lifts expired protections, so forgotten temporary protections
+// do not keep review environments forever.
+func (n *NsInformer) ProtectionTicker(ctx context.Context) {
+	ticker := time.NewTicker(PROTECTION_TICK)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Finishing protection ticker...")
+			return
+		case <-ticker.C:
+			n.liftExpiredProtections(ctx)
+		}
+	}
+}
+
+// liftExpiredProtections removes the protection annotations whose time has passed and returns
+// the number of lifted protections. The namespaces are watched again with their deletion timestamps,
+// moved to the end of the grace period if they are earlier.
+func (n *NsInformer) liftExpiredProtections(ctx context.Context) int {
+	namespaces, err := n.nsLister.List(labels.Everything())
+	if err != nil {
+		n.logger.Error("Could not list namespaces to lift expired protections", "ERROR:", err)
+		return 0
+	}
+
+	lifted := 0
+	now := time.Now().UTC()
+	for _, ns := range namespaces {
+		protection := n.protectionOf(ns)
+		if !n.isSelected(ns) || !protection.isExpired(now) {
+			continue
+		}
+		if n.config().DryRun {
+			n.logger.Info(
+				"[DRY-RUN] want to lift expired protection",
+				"namespace", ns.Name,
+				"ProtectedUntil", protection.Until.Format(time.RFC3339),
+			)
+			continue
+		}
+		if err := n.liftProtection(ctx, ns, now); err != nil {
+			continue
+		}
+		lifted++
+		n.logger.Info(
+			"Protection expired, the namespace is watched again",
+			"namespace", ns.Name,
+			"ProtectedUntil", protection.Until.Format(time.RFC3339),
+			"Reason", protection.Reason,
+		)
+		n.notifyPolicyEvent(ctx, ns, "ProtectionExpired", "Protection expired at "+protection.Until.Format(time.RFC3339))
+	}
+	return lifted
+}
+
+func (n *NsInformer) liftProtection(ctx context.Context, ns *corev1.Namespace, now time.Time) error {
+	annotations := map[string]interface{}{
+		n.config().NsPreserveAnnotation:         nil,
+		n.config().NsProtectedUntilAnnotation:   nil,
+		n.config().NsProtectionReasonAnnotation: nil,
+	}
+	graceEnd := now.Add(PROTECTION_GRACE_PERIOD)
+	if deletionTs, err := n.getNsDeletionTimespamp(ns); err != nil || deletionTs.Before(graceEnd) {
+		annotations[n.config().AnnotationKey] = graceEnd.Format(time.RFC3339)
+	}
+	return n.patchNsAnnotations(ctx, ns, annotations)
+}
+
+// Protect protects the namespace for the reason, until the time if it is not zero.
+func (n *NsInformer) Protect(ctx context.Context, ns *corev1.Namespace, reason string, until time.Time) error {
+	if reason == "" {
+		return errProtectionReasonMissing
+	}
+	annotations := map[string]interface{}{
+		n.config().NsPreserveAnnotation:         "true",
+		n.config().NsProtectionReasonAnnotation: reason,
+		n.config().NsProtectedUntilAnnotation:   nil,
+	}
+	if !until.IsZero() {
+		annotations[n.config().NsProtectedUntilAnnotation] = until.UTC().Format(time.RFC3339)
+	}
+	return n.patchNsAnnotations(ctx, ns, annotations)
+}
+
+func (n *NsInformer) Unprotect(ctx context.Context, ns *corev1.Namespace) error {
+	return n.removeNsAnnotations(
+		ctx,
+		ns,
+		n.config().NsPreserveAnnotation,
+		n.config().NsProtectedUntilAnnotation,
+		n.config().NsProtectionReasonAnnotation,
+	)
+}
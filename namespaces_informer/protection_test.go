@@ -0,0 +1,172 @@
+package namespaces_informer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestProtectionOf(t *testing.T) {
+	n, _ := newTestInformer(t, newTestConfig())
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		isSet       bool
+		isInvalid   bool
+		isExpired   bool
+		isProtected bool
+	}{
+		{
+			name: "not protected",
+		},
+		{
+			name:        "permanent",
+			annotations: map[string]string{"review-reaper-protected": "true", "review-reaper-protected-reason": "demo"},
+			isSet:       true,
+			isProtected: true,
+		},
+		{
+			name:        "until the future",
+			annotations: map[string]string{"review-reaper-protected-until": now.Add(time.Hour).Format(time.RFC3339)},
+			isSet:       true,
+			isProtected: true,
+		},
+		{
+			name:        "expired",
+			annotations: map[string]string{"review-reaper-protected-until": now.Add(-time.Hour).Format(time.RFC3339)},
+			isSet:       true,
+			isExpired:   true,
+		},
+		{
+			name: "expired with the preserve annotation",
+			annotations: map[string]string{
+				"review-reaper-protected":       "true",
+				"review-reaper-protected-until": now.Add(-time.Hour).Format(time.RFC3339),
+			},
+			isSet:     true,
+			isExpired: true,
+		},
+		{
+			name:        "invalid until keeps the protection",
+			annotations: map[string]string{"review-reaper-protected-until": "tomorrow"},
+			isSet:       true,
+			isInvalid:   true,
+			isProtected: true,
+		},
+		{
+			name:        "empty until keeps the protection",
+			annotations: map[string]string{"review-reaper-protected-until": ""},
+			isSet:       true,
+			isInvalid:   true,
+			isProtected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := newTestNamespace("review-login", tt.annotations)
+			protection := n.protectionOf(ns)
+
+			if protection.IsSet != tt.isSet || (protection.UntilErr != nil) != tt.isInvalid {
+				t.Errorf("expected set %v and invalid %v, got %+v", tt.isSet, tt.isInvalid, protection)
+			}
+			if isExpired := protection.isExpired(now); isExpired != tt.isExpired {
+				t.Errorf("expected expired %v, got %v", tt.isExpired, isExpired)
+			}
+			if isProtected := n.isProtected(ns); isProtected != tt.isProtected {
+				t.Errorf("expected protected %v, got %v", tt.isProtected, isProtected)
+			}
+		})
+	}
+}
+
+func TestLiftExpiredProtections(t *testing.T) {
+	expired := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	protections := map[string]map[string]string{
+		"review-expired": {
+			"review-reaper-protected":        "true",
+			"review-reaper-protected-until":  expired,
+			"review-reaper-protected-reason": "demo",
+			"delete_after":                   "2030-01-01T00:00:00Z",
+		},
+		"review-overdue": {
+			"review-reaper-protected-until": expired,
+			"delete_after":                  "2020-01-01T00:00:00Z",
+		},
+		"review-unannotated": {"review-reaper-protected-until": expired},
+		"review-active": {
+			"review-reaper-protected-until": time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+		},
+		"review-invalid":     {"review-reaper-protected-until": "tomorrow"},
+		"review-permanent":   {"review-reaper-protected": "true"},
+		"review-missing":     {"review-reaper-protected-reason": "demo"},
+		"production-expired": {"review-reaper-protected-until": expired},
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		namespaces := make([]*corev1.Namespace, 0, len(protections))
+		for name, annotations := range protections {
+			copied := make(map[string]string, len(annotations))
+			for key, value := range annotations {
+				copied[key] = value
+			}
+			namespaces = append(namespaces, newTestNamespace(name, copied))
+		}
+		config := newTestConfig()
+		config.DryRun = dryRun
+		n, client := newTestInformer(t, config, namespaces...)
+
+		lifted := n.liftExpiredProtections(context.Background())
+
+		expectedLifted := 3
+		if dryRun {
+			expectedLifted = 0
+		}
+		if lifted != expectedLifted {
+			t.Errorf("dry run %v: expected %d lifted protections, got %d", dryRun, expectedLifted, lifted)
+		}
+		for name, annotations := range protections {
+			ns := getTestNamespace(t, client, name)
+			isLifted := strings.HasPrefix(name, "review-") && annotations["review-reaper-protected-until"] == expired && !dryRun
+			for key := range annotations {
+				_, ok := ns.Annotations[key]
+				if isKept := key == "delete_after" || !isLifted; ok != isKept {
+					t.Errorf("dry run %v: %s annotation %s kept: %v, expected %v", dryRun, name, key, ok, isKept)
+				}
+			}
+		}
+
+		// Deletion timestamps which are earlier than the grace period are moved to its end.
+		graceEnd := time.Now().UTC().Add(PROTECTION_GRACE_PERIOD - time.Minute)
+		for _, name := range []string{"review-overdue", "review-unannotated"} {
+			deleteAfter, err := time.Parse(time.RFC3339, getTestNamespace(t, client, name).Annotations["delete_after"])
+			if isMoved := err == nil && deleteAfter.After(graceEnd); isMoved == dryRun {
+				t.Errorf("dry run %v: %s deletion moved to the grace period end: %v", dryRun, name, isMoved)
+			}
+		}
+		if deleteAfter := getTestNamespace(t, client, "review-expired").Annotations["delete_after"]; deleteAfter != "2030-01-01T00:00:00Z" {
+			t.Errorf("dry run %v: expected the later deletion timestamp to be kept, got %s", dryRun, deleteAfter)
+		}
+	}
+}
+
+func TestExplainExpiredProtection(t *testing.T) {
+	n, _ := newTestInformer(t, newTestConfig())
+	ns := newTestNamespace("review-login", map[string]string{
+		"review-reaper-protected-until": time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+		"delete_after":                  "2020-01-01T00:00:00Z",
+	})
+
+	explanation := n.Explain(ns)
+	estimated, err := time.Parse(time.RFC3339, explanation.EstimatedDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graceEnd := time.Now().UTC().Add(PROTECTION_GRACE_PERIOD - time.Minute); estimated.Before(graceEnd) {
+		t.Errorf("expected the deletion after the grace period, got %s: %v", explanation.EstimatedDeletion, explanation.Reasons)
+	}
+}
@@ -0,0 +1,272 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	QUARANTINE_INGRESS_CLASS           = "review-reaper-quarantine"
+	IngressClassAnnotation             = "review-reaper/ingress-class"
+	LegacyIngressClassAnnotation       = "kubernetes.io/ingress.class"
+	LegacyIngressClassBackupAnnotation = "review-reaper/legacy-ingress-class"
+)
+
+func (n *NsInformer) isQuarantineLifted(oldNs *corev1.Namespace, newNs *corev1.Namespace) bool {
+	_, wasQuarantined := oldNs.Annotations[n.config().NsQuarantineAnnotation]
+	_, isQuarantined := newNs.Annotations[n.config().NsQuarantineAnnotation]
+	return wasQuarantined && !isQuarantined && newNs.DeletionTimestamp == nil
+}
+
+// quarantineExpiredNamespaces puts freshly expired namespaces into quarantine and
+// returns only those which have been quarantined for longer than the configured period.
+func (n *NsInformer) quarantineExpiredNamespaces(
+	ctx context.Context,
+	namespaces []*corev1.Namespace,
+) []*corev1.Namespace {
+	timeNow := time.Now().UTC()
+	period := n.config().Quarantine.Duration
+	quarantineIsOver := make([]*corev1.Namespace, 0)
+
+	for _, ns := range namespaces {
+		quarantinedAtAnnotation, ok := ns.Annotations[n.config().NsQuarantineAnnotation]
+		if !ok {
+			n.quarantineNamespace(ctx, ns)
+			continue
+		}
+
+		quarantinedAt, err := time.Parse(time.RFC3339, quarantinedAtAnnotation)
+		if err != nil {
+			n.logger.Error("Invalid quarantine timestamp", "namespace", ns.Name, "Value", quarantinedAtAnnotation)
+			continue
+		}
+		if quarantinedAt.Add(period).Before(timeNow) {
+			quarantineIsOver = append(quarantineIsOver, ns)
+		}
+	}
+
+	return quarantineIsOver
+}
+
+func (n *NsInformer) quarantineNamespace(ctx context.Context, ns *corev1.Namespace) error {
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to quarantine", "namespace", ns.Name)
+		return nil
+	}
+
+	if _, err := n.scaleDownWorkloads(ctx, ns); err != nil {
+		return err
+	}
+	if err := n.disableIngresses(ctx, ns); err != nil {
+		return err
+	}
+
+	err := n.updateNsAnnotations(ctx, ns, map[string]string{
+		n.config().NsQuarantineAnnotation: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err == nil {
+		n.logger.Info(
+			"Namespace quarantined",
+			"namespace",
+			ns.Name,
+			"DeleteAfter",
+			utils.FormatDuration(n.config().Quarantine.Duration),
+		)
+	}
+	return err
+}
+
+// restoreFromQuarantine is called when someone removes the quarantine annotation.
+// The deletion timestamp is shifted by retention from now, otherwise the namespace
+// would be quarantined again in the next maintenance iteration. A deletion timestamp
+// in the future, like the one set by Extend, is kept.
+func (n *NsInformer) restoreFromQuarantine(ctx context.Context, ns *corev1.Namespace) error {
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to restore from quarantine", "namespace", ns.Name)
+		return nil
+	}
+
+	if err := n.restoreWorkloads(ctx, ns); err != nil {
+		return err
+	}
+	if err := n.enableIngresses(ctx, ns); err != nil {
+		return err
+	}
+
+	newRetention := n.shiftTimeStampByRetention(ns, time.Now()).UTC().Format(time.RFC3339)
+	if deletionTs, err := n.getNsDeletionTimespamp(ns); err == nil && deletionTs.After(time.Now()) {
+		newRetention = deletionTs.Format(time.RFC3339)
+	}
+	err := n.patchNsAnnotations(ctx, ns, map[string]interface{}{
+		n.config().AnnotationKey:         newRetention,
+		n.config().NsExpiredByAnnotation: nil,
+		HibernatedAnnotation:             nil,
+	})
+	if err == nil {
+		n.logger.Info("Namespace restored from quarantine", "namespace", ns.Name, "DeletionTimestamp", newRetention)
+	}
+	return err
+}
+
+// restoreLiftedQuarantines restores namespaces whose quarantine annotation was removed while no update
+// was seen, like when the reaper was down or runs once: their workloads or ingresses still keep the state
+// saved by quarantine. It returns the names of the restored namespaces, which the informer cache does not
+// show restored yet.
+func (n *NsInformer) restoreLiftedQuarantines(ctx context.Context) map[string]bool {
+	restored := make(map[string]bool)
+	namespaces, err := n.nsLister.List(labels.Everything())
+	if err != nil {
+		n.logger.Error("Could not list namespaces to restore from quarantine", "ERROR:", err)
+		return restored
+	}
+
+	for _, ns := range namespaces {
+		_, isQuarantined := ns.Annotations[n.config().NsQuarantineAnnotation]
+		// Hibernated workloads are woken up by the hibernation ticker.
+		_, isHibernated := ns.Annotations[HibernatedAnnotation]
+		if isQuarantined || isHibernated || ns.DeletionTimestamp != nil || !n.isSelected(ns) {
+			continue
+		}
+		if !n.hasQuarantineLeftovers(ctx, ns) {
+			continue
+		}
+		if err := n.restoreFromQuarantine(ctx, ns); err == nil {
+			restored[ns.Name] = true
+		}
+	}
+	return restored
+}
+
+// hasQuarantineLeftovers tells whether workloads or ingresses of the namespace are still scaled down
+// or disabled by the reaper.
+func (n *NsInformer) hasQuarantineLeftovers(ctx context.Context, ns *corev1.Namespace) bool {
+	workloads, err := n.listWorkloads(ctx, ns.Name)
+	if err != nil {
+		n.logger.Error("Could not list workloads to check quarantine", "namespace", ns.Name, "ERROR:", err)
+		return false
+	}
+	for _, w := range workloads {
+		if _, ok := w.annotations[ReplicasAnnotation]; ok {
+			return true
+		}
+	}
+
+	ingresses, err := n.client.NetworkingV1().Ingresses(ns.Name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		n.logger.Error("Could not list ingresses to check quarantine", "namespace", ns.Name, "ERROR:", err)
+		return false
+	}
+	for _, ingress := range ingresses.Items {
+		if _, ok := ingress.Annotations[IngressClassAnnotation]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// disableIngresses moves all ingresses of the namespace to a non-existent ingress class,
+// so ingress controllers stop serving them. Original classes are kept in annotations.
+func (n *NsInformer) disableIngresses(ctx context.Context, ns *corev1.Namespace) error {
+	ingressesClient := n.client.NetworkingV1().Ingresses(ns.Name)
+	ingresses, err := ingressesClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		n.logger.Error("Could not list ingresses", "namespace", ns.Name, "ERROR:", err)
+		return err
+	}
+
+	for _, ingress := range ingresses.Items {
+		if _, ok := ingress.Annotations[IngressClassAnnotation]; ok {
+			continue
+		}
+
+		annotations := map[string]interface{}{
+			IngressClassAnnotation: ingressClassName(&ingress),
+		}
+		if legacyClass, ok := ingress.Annotations[LegacyIngressClassAnnotation]; ok {
+			annotations[LegacyIngressClassAnnotation] = QUARANTINE_INGRESS_CLASS
+			annotations[LegacyIngressClassBackupAnnotation] = legacyClass
+		}
+
+		err := n.patchIngress(ctx, ns.Name, ingress.Name, annotations, QUARANTINE_INGRESS_CLASS)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NsInformer) enableIngresses(ctx context.Context, ns *corev1.Namespace) error {
+	ingressesClient := n.client.NetworkingV1().Ingresses(ns.Name)
+	ingresses, err := ingressesClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		n.logger.Error("Could not list ingresses", "namespace", ns.Name, "ERROR:", err)
+		return err
+	}
+
+	for _, ingress := range ingresses.Items {
+		originalClass, ok := ingress.Annotations[IngressClassAnnotation]
+		if !ok {
+			continue
+		}
+
+		annotations := map[string]interface{}{IngressClassAnnotation: nil}
+		if legacyClass, ok := ingress.Annotations[LegacyIngressClassBackupAnnotation]; ok {
+			annotations[LegacyIngressClassAnnotation] = legacyClass
+			annotations[LegacyIngressClassBackupAnnotation] = nil
+		}
+
+		var className interface{}
+		if originalClass != "" {
+			className = originalClass
+		}
+
+		err := n.patchIngress(ctx, ns.Name, ingress.Name, annotations, className)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NsInformer) patchIngress(
+	ctx context.Context,
+	namespace string,
+	name string,
+	annotations map[string]interface{},
+	className interface{},
+) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+		"spec":     map[string]interface{}{"ingressClassName": className},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = n.client.NetworkingV1().Ingresses(namespace).Patch(
+		ctx,
+		name,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{},
+	)
+	if err != nil {
+		n.logger.Error("Could not patch ingress", "namespace", namespace, "Ingress", name, "ERROR:", err)
+	}
+	return err
+}
+
+func ingressClassName(ingress *networkingv1.Ingress) string {
+	if ingress.Spec.IngressClassName == nil {
+		return ""
+	}
+	return *ingress.Spec.IngressClassName
+}
@@ -0,0 +1,167 @@
+package namespaces_informer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaintenanceIterationQuarantine(t *testing.T) {
+	now := time.Now().UTC()
+	expiredAt := now.Add(-48 * time.Hour).Format(time.RFC3339)
+
+	config := newTestConfig()
+	config.Quarantine.Enabled = true
+	config.Quarantine.Duration = 24 * time.Hour
+	n, client := newTestInformer(
+		t,
+		config,
+		newTestNamespace("review-fresh", map[string]string{"delete_after": expiredAt}),
+		newTestNamespace("review-waiting", map[string]string{
+			"delete_after":                expiredAt,
+			config.NsQuarantineAnnotation: now.Add(-time.Hour).Format(time.RFC3339),
+		}),
+		newTestNamespace("review-due", map[string]string{
+			"delete_after":                expiredAt,
+			config.NsQuarantineAnnotation: now.Add(-25 * time.Hour).Format(time.RFC3339),
+		}),
+	)
+
+	summary, err := n.maintenanceIteration(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the due namespace counts, the others wait in quarantine and the ticker naps.
+	if summary.Expired != 1 || summary.Deleted != 1 {
+		t.Errorf("expected 1 expired and deleted namespace, got %+v", summary)
+	}
+
+	if _, err := client.CoreV1().Namespaces().Get(context.Background(), "review-due", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected review-due to be deleted, got %v", err)
+	}
+	if _, ok := getTestNamespace(t, client, "review-fresh").Annotations[config.NsQuarantineAnnotation]; !ok {
+		t.Error("expected review-fresh to be quarantined")
+	}
+	getTestNamespace(t, client, "review-waiting")
+}
+
+// TestRestoreLiftedQuarantine removes the quarantine annotation while the reaper is down, so the
+// update is never seen and the next iteration finds the workloads and ingresses left behind.
+func TestRestoreLiftedQuarantine(t *testing.T) {
+	expiredAt := time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339)
+	quarantineClass := QUARANTINE_INGRESS_CLASS
+	replicas := int32(0)
+
+	config := newTestConfig()
+	config.Quarantine.Enabled = true
+	config.Quarantine.Duration = 24 * time.Hour
+	n, client := newTestInformer(
+		t,
+		config,
+		newTestNamespace("review-lifted", map[string]string{"delete_after": expiredAt}),
+		newTestNamespace("review-hibernated", map[string]string{
+			"delete_after":       time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+			HibernatedAnnotation: expiredAt,
+		}),
+	)
+	ctx := context.Background()
+	for _, namespace := range []string{"review-lifted", "review-hibernated"} {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace, Annotations: map[string]string{ReplicasAnnotation: "3"}},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		}
+		if _, err := client.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "review-lifted", Annotations: map[string]string{IngressClassAnnotation: "nginx"}},
+		Spec:       networkingv1.IngressSpec{IngressClassName: &quarantineClass},
+	}
+	if _, err := client.NetworkingV1().Ingresses("review-lifted").Create(ctx, ingress, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := n.maintenanceIteration(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Expired != 0 {
+		t.Errorf("expected nothing expired, got %+v", summary)
+	}
+
+	ns := getTestNamespace(t, client, "review-lifted")
+	if _, ok := ns.Annotations[config.NsQuarantineAnnotation]; ok {
+		t.Error("expected review-lifted not to be quarantined again")
+	}
+	if deleteAfter, _ := time.Parse(time.RFC3339, ns.Annotations["delete_after"]); !deleteAfter.After(time.Now()) {
+		t.Errorf("expected the deletion timestamp to be shifted, got %s", ns.Annotations["delete_after"])
+	}
+	deployment, err := client.AppsV1().Deployments("review-lifted").Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := deployment.Annotations[ReplicasAnnotation]; ok || *deployment.Spec.Replicas != 3 {
+		t.Errorf("expected the deployment to be scaled back to 3, got %d", *deployment.Spec.Replicas)
+	}
+	restoredIngress, err := client.NetworkingV1().Ingresses("review-lifted").Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restoredIngress.Annotations[IngressClassAnnotation]; ok || ingressClassName(restoredIngress) != "nginx" {
+		t.Errorf("expected the ingress class nginx, got %q", ingressClassName(restoredIngress))
+	}
+
+	hibernated, err := client.AppsV1().Deployments("review-hibernated").Get(ctx, "app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *hibernated.Spec.Replicas != 0 {
+		t.Error("expected the hibernated deployment to stay scaled down")
+	}
+}
+
+// TestExtendQuarantined lifts the quarantine with the extension, and the restore keeps the extended
+// deletion timestamp instead of shifting it by the retention.
+func TestExtendQuarantined(t *testing.T) {
+	config := newTestConfig()
+	config.Quarantine.Enabled = true
+	config.Quarantine.Duration = 24 * time.Hour
+	n, client := newTestInformer(
+		t,
+		config,
+		newTestNamespace("review-login", map[string]string{
+			"delete_after":                time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339),
+			config.NsExpiredByAnnotation:  QUOTA_EXPIRED_BY,
+			config.NsQuarantineAnnotation: time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+		}),
+	)
+	createTestDeployment(t, client, "review-login", 0, map[string]string{ReplicasAnnotation: "2"})
+
+	ctx := context.Background()
+	if err := n.Extend(ctx, getTestNamespace(t, client, "review-login"), 2*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	ns := getTestNamespace(t, client, "review-login")
+	for _, key := range []string{config.NsExpiredByAnnotation, config.NsQuarantineAnnotation} {
+		if _, ok := ns.Annotations[key]; ok {
+			t.Errorf("expected %s to be removed", key)
+		}
+	}
+	extended := ns.Annotations["delete_after"]
+
+	if err := n.restoreFromQuarantine(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	if deleteAfter := getTestNamespace(t, client, "review-login").Annotations["delete_after"]; deleteAfter != extended {
+		t.Errorf("expected the extended deletion timestamp %s to be kept, got %s", extended, deleteAfter)
+	}
+	if deployment := getTestDeployment(t, client, "review-login"); *deployment.Spec.Replicas != 2 {
+		t.Errorf("expected the deployment scaled back to 2, got %d", *deployment.Spec.Replicas)
+	}
+}
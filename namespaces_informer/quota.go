@@ -0,0 +1,211 @@
+package namespaces_informer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	QUOTA_STRATEGY_OLDEST       = "oldest"
+	QUOTA_STRATEGY_LEAST_ACTIVE = "least-active"
+	QUOTA_STRATEGY_SHORTEST_TTL = "shortest-ttl"
+	QUOTA_EXPIRED_BY            = "quota"
+	QUOTA_EVENT_REASON          = "ReviewQuotaExceeded"
+	EVENT_SOURCE                = "review-reaper"
+)
+
+// enforceQuota is called for a newly added watched namespace. When its group or the total
+// number of live review namespaces exceeds the quota, namespaces picked by the configured
+// strategy are expired, so they are deleted in the next maintenance window.
+// The new namespace itself is never evicted.
+func (n *NsInformer) enforceQuota(ctx context.Context, added *corev1.Namespace) {
+	if !n.isLive(added) {
+		return
+	}
+
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		n.logger.Error("Could not list watched namespaces to check quota", err)
+		return
+	}
+
+	live := make([]*corev1.Namespace, 0, len(watchedNamespaces))
+	for _, ns := range watchedNamespaces {
+		if n.isLive(ns) {
+			live = append(live, ns)
+		}
+	}
+
+	evicted := make(map[string]bool)
+	groupLabel := n.config().Quota.GroupLabel
+
+	if group, ok := added.Labels[groupLabel]; ok && n.config().Quota.MaxPerGroup > 0 {
+		members := make([]*corev1.Namespace, 0)
+		for _, ns := range live {
+			if ns.Labels[groupLabel] == group {
+				members = append(members, ns)
+			}
+		}
+		for _, ns := range n.pickForEviction(members, added, n.config().Quota.MaxPerGroup) {
+			reason := fmt.Sprintf("%s=%s has more than %d review namespaces", groupLabel, group, n.config().Quota.MaxPerGroup)
+			n.evictNamespace(ctx, ns, QUOTA_EXPIRED_BY, QUOTA_EVENT_REASON, reason)
+			evicted[ns.Name] = true
+		}
+	}
+
+	if n.config().Quota.MaxTotal > 0 {
+		remaining := make([]*corev1.Namespace, 0, len(live))
+		for _, ns := range live {
+			if !evicted[ns.Name] {
+				remaining = append(remaining, ns)
+			}
+		}
+		for _, ns := range n.pickForEviction(remaining, added, n.config().Quota.MaxTotal) {
+			reason := fmt.Sprintf("cluster has more than %d review namespaces", n.config().Quota.MaxTotal)
+			n.evictNamespace(ctx, ns, QUOTA_EXPIRED_BY, QUOTA_EVENT_REASON, reason)
+		}
+	}
+}
+
+// isLive reports whether the namespace counts against the quota: it is not terminating
+// and is not already expired or evicted.
+func (n *NsInformer) isLive(ns *corev1.Namespace) bool {
+	if ns.DeletionTimestamp != nil {
+		return false
+	}
+	if _, ok := ns.Annotations[n.config().NsExpiredByAnnotation]; ok {
+		return false
+	}
+	n.quotaMutex.Lock()
+	defer n.quotaMutex.Unlock()
+	return !n.quotaEvicted[ns.Name]
+}
+
+// pickForEviction returns the namespaces exceeding the limit, ordered by the configured strategy.
+func (n *NsInformer) pickForEviction(
+	namespaces []*corev1.Namespace,
+	added *corev1.Namespace,
+	limit int,
+) []*corev1.Namespace {
+	excess := len(namespaces) - limit
+	if excess <= 0 {
+		return nil
+	}
+
+	candidates := make([]*corev1.Namespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if ns.Name != added.Name {
+			candidates = append(candidates, ns)
+		}
+	}
+
+	rank := make(map[string]time.Time, len(candidates))
+	for _, ns := range candidates {
+		rank[ns.Name] = n.evictionRank(ns)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return rank[candidates[i].Name].Before(rank[candidates[j].Name])
+	})
+
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	return candidates[:excess]
+}
+
+// evictionRank is the time by which namespaces are ordered for eviction, the earliest goes first.
+func (n *NsInformer) evictionRank(ns *corev1.Namespace) time.Time {
+	switch n.config().Quota.Strategy {
+	case QUOTA_STRATEGY_LEAST_ACTIVE:
+		releases, _ := n.listNamespaceReleases(ns)
+		if len(releases) > 0 {
+			return n.latestDeployedRelease(releases).Info.LastDeployed.UTC().Time
+		}
+	case QUOTA_STRATEGY_SHORTEST_TTL:
+		if deletionTs, err := n.getNsDeletionTimespamp(ns); err == nil {
+			return deletionTs
+		}
+	}
+	return n.getNsCreationTimestamp(ns)
+}
+
+// evictNamespace expires a live namespace ahead of its retention and notifies its owners.
+// It reports whether the namespace was expired, which it is not in dry run or on failure.
+func (n *NsInformer) evictNamespace(
+	ctx context.Context,
+	ns *corev1.Namespace,
+	expiredBy string,
+	eventReason string,
+	reason string,
+) bool {
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to evict", "namespace", ns.Name, "By", expiredBy, "Reason", reason)
+		return false
+	}
+
+	if err := n.expireNamespace(ctx, ns, expiredBy); err != nil {
+		return false
+	}
+	n.quotaMutex.Lock()
+	n.quotaEvicted[ns.Name] = true
+	n.quotaMutex.Unlock()
+
+	n.logger.Warn(
+		"Namespace evicted, will be deleted in the next maintenance window",
+		"namespace",
+		ns.Name,
+		"By",
+		expiredBy,
+		"Reason",
+		reason,
+	)
+	n.recordNamespaceEvent(ctx, ns, eventReason, fmt.Sprintf("Evicted by review-reaper %s: %s", expiredBy, reason))
+	return true
+}
+
+// forgetEviction drops a deleted namespace from the evicted set.
+func (n *NsInformer) forgetEviction(namespace *corev1.Namespace) {
+	n.quotaMutex.Lock()
+	defer n.quotaMutex.Unlock()
+	delete(n.quotaEvicted, namespace.Name)
+}
+
+// recordNamespaceEvent creates a warning Event inside the namespace, so its owners can see
+// the reason with "kubectl get events".
+func (n *NsInformer) recordNamespaceEvent(
+	ctx context.Context,
+	ns *corev1.Namespace,
+	reason string,
+	message string,
+) {
+	timeNow := metav1.NewTime(time.Now().UTC())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: ns.Name + ".",
+			Namespace:    ns.Name,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       ns.Name,
+			UID:        ns.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: EVENT_SOURCE},
+		FirstTimestamp: timeNow,
+		LastTimestamp:  timeNow,
+		Count:          1,
+	}
+
+	_, err := n.client.CoreV1().Events(ns.Name).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		n.logger.Error("Could not record event", "namespace", ns.Name, "ERROR:", err)
+	}
+}
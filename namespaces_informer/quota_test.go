@@ -0,0 +1,120 @@
+package namespaces_informer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newQuotaTestNamespace is created the given hours ago and deleted after the given hours from now.
+func newQuotaTestNamespace(name string, team string, createdHoursAgo int, deleteInHours int) *corev1.Namespace {
+	ns := newTestNamespace(name, map[string]string{
+		"delete_after": time.Now().UTC().Add(time.Duration(deleteInHours) * time.Hour).Format(time.RFC3339),
+	})
+	ns.Labels = map[string]string{"team": team}
+	ns.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Duration(createdHoursAgo) * time.Hour))
+	return ns
+}
+
+func TestEnforceQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		maxPerGroup int
+		maxTotal    int
+		evicted     []string
+	}{
+		{
+			name:        "oldest of the group",
+			strategy:    QUOTA_STRATEGY_OLDEST,
+			maxPerGroup: 2,
+			evicted:     []string{"review-a-old"},
+		},
+		{
+			name:        "shortest ttl of the group",
+			strategy:    QUOTA_STRATEGY_SHORTEST_TTL,
+			maxPerGroup: 2,
+			evicted:     []string{"review-a-young"},
+		},
+		{
+			name:     "oldest of the cluster",
+			strategy: QUOTA_STRATEGY_OLDEST,
+			maxTotal: 2,
+			evicted:  []string{"review-a-old", "review-b"},
+		},
+		{
+			name:        "group evictions count against the total",
+			strategy:    QUOTA_STRATEGY_OLDEST,
+			maxPerGroup: 2,
+			maxTotal:    3,
+			evicted:     []string{"review-a-old"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Quota.Enabled = true
+			config.Quota.GroupLabel = "team"
+			config.Quota.Strategy = tt.strategy
+			config.Quota.MaxPerGroup = tt.maxPerGroup
+			config.Quota.MaxTotal = tt.maxTotal
+			added := newQuotaTestNamespace("review-a-new", "a", 0, 48)
+			expired := newQuotaTestNamespace("review-a-expired", "a", 5, 0)
+			expired.Annotations[config.NsExpiredByAnnotation] = QUOTA_EXPIRED_BY
+			n, client := newTestInformer(
+				t,
+				config,
+				added,
+				expired,
+				newQuotaTestNamespace("review-a-old", "a", 4, 24),
+				newQuotaTestNamespace("review-a-young", "a", 1, 2),
+				newQuotaTestNamespace("review-b", "b", 3, 24),
+			)
+
+			n.enforceQuota(context.Background(), added)
+
+			isEvicted := make(map[string]bool)
+			for _, name := range tt.evicted {
+				isEvicted[name] = true
+			}
+			for _, name := range []string{"review-a-new", "review-a-old", "review-a-young", "review-b"} {
+				_, ok := getTestNamespace(t, client, name).Annotations[config.NsExpiredByAnnotation]
+				if ok != isEvicted[name] {
+					t.Errorf("%s evicted: %v, expected %v", name, ok, isEvicted[name])
+				}
+			}
+
+			for _, name := range tt.evicted {
+				events, err := client.CoreV1().Events(name).List(context.Background(), metav1.ListOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(events.Items) != 1 || events.Items[0].Reason != QUOTA_EVENT_REASON {
+					t.Errorf("expected a %s event in %s, got %+v", QUOTA_EVENT_REASON, name, events.Items)
+				}
+			}
+		})
+	}
+}
+
+func TestEnforceQuotaDryRun(t *testing.T) {
+	config := newTestConfig()
+	config.DryRun = true
+	config.Quota.Enabled = true
+	config.Quota.Strategy = QUOTA_STRATEGY_OLDEST
+	config.Quota.MaxTotal = 1
+	added := newQuotaTestNamespace("review-new", "a", 0, 48)
+	n, client := newTestInformer(t, config, added, newQuotaTestNamespace("review-old", "a", 4, 24))
+
+	n.enforceQuota(context.Background(), added)
+
+	if _, ok := getTestNamespace(t, client, "review-old").Annotations[config.NsExpiredByAnnotation]; ok {
+		t.Error("expected review-old not to be evicted in dry run")
+	}
+	if !n.isLive(added) {
+		t.Error("expected review-new to stay live")
+	}
+}
@@ -0,0 +1,95 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/metrics"
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"reflect"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// watchConfig reloads the config when its file changes. An invalid config is rejected
+// and the current one stays in use.
+func (n *NsInformer) watchConfig(ctx context.Context) {
+	utils.WatchConfig(func(newConfig utils.Config, err error) {
+		if ctx.Err() != nil {
+			return
+		}
+		metrics.ConfigReloaded(err)
+		if err != nil {
+			n.logger.Error("Config reload failed, keeping the current config", "ERROR:", err)
+			return
+		}
+		n.reloadConfig(ctx, newConfig)
+	})
+}
+
+// reloadConfig swaps the config and re-evaluates the watched namespaces with it.
+// Sections which start servers, clients or tickers are kept as they are until restart.
+func (n *NsInformer) reloadConfig(ctx context.Context, newConfig utils.Config) {
+	current := n.config()
+	if changed := keepRestartOnly(current, &newConfig); len(changed) > 0 {
+		n.logger.Warn("Config changes require a restart to take effect", "Keys", changed)
+	}
+
+	n.configMutex.Lock()
+	n.appConfig = &newConfig
+	n.configMutex.Unlock()
+
+	n.logger.SetLevel(hclog.LevelFromString(newConfig.LogLevel))
+	n.logger.Info("Config reloaded")
+	for _, warning := range utils.ConfigDeprecations() {
+		n.logger.Warn(warning)
+	}
+
+	n.reconcileWatched(ctx)
+}
+
+// reconcileWatched annotates and enforces the quota on all watched namespaces, after the config
+// or policies changed which namespaces are watched.
+func (n *NsInformer) reconcileWatched(ctx context.Context) {
+	watchedNamespaces, err := n.listWatchedNamespaces()
+	if err != nil {
+		n.logger.Error("Could not list watched namespaces", "ERROR:", err)
+		return
+	}
+	for _, ns := range watchedNamespaces {
+		n.ensureAnnotated(ctx, ns)
+		if n.config().Quota.Enabled {
+			n.enforceQuota(ctx, ns)
+		}
+	}
+}
+
+// keepRestartOnly copies the settings applied only at start from the current config
+// and returns the names of those which were changed.
+func keepRestartOnly(current *utils.Config, newConfig *utils.Config) []string {
+	changed := make([]string, 0)
+	keep := func(name string, currentValue interface{}, newValue interface{}) {
+		newField := reflect.ValueOf(newValue).Elem()
+		currentField := reflect.ValueOf(currentValue).Elem()
+		if !reflect.DeepEqual(currentField.Interface(), newField.Interface()) {
+			changed = append(changed, name)
+			newField.Set(currentField)
+		}
+	}
+
+	keep("Webhook", &current.Webhook, &newConfig.Webhook)
+	keep("AdminAPI", &current.AdminAPI, &newConfig.AdminAPI)
+	keep("Metrics", &current.Metrics, &newConfig.Metrics)
+	keep("Policies.Enabled", &current.Policies.Enabled, &newConfig.Policies.Enabled)
+	keep("Claims.Enabled", &current.Claims.Enabled, &newConfig.Claims.Enabled)
+	keep("Admission.Enabled", &current.Admission.Enabled, &newConfig.Admission.Enabled)
+	keep("Admission.ListenAddress", &current.Admission.ListenAddress, &newConfig.Admission.ListenAddress)
+	keep("Admission.CertFile", &current.Admission.CertFile, &newConfig.Admission.CertFile)
+	keep("Admission.KeyFile", &current.Admission.KeyFile, &newConfig.Admission.KeyFile)
+	keep("Forge", &current.Forge, &newConfig.Forge)
+	keep("Backup", &current.Backup, &newConfig.Backup)
+	keep("Throttle", &current.Throttle, &newConfig.Throttle)
+	keep("Hibernation.Enabled", &current.Hibernation.Enabled, &newConfig.Hibernation.Enabled)
+	keep("TerminationWatch.Enabled", &current.TerminationWatch.Enabled, &newConfig.TerminationWatch.Enabled)
+	keep("Budget.Enabled", &current.Budget.Enabled, &newConfig.Budget.Enabled)
+	keep("Budget.CheckInterval", &current.Budget.CheckInterval, &newConfig.Budget.CheckInterval)
+	return changed
+}
@@ -0,0 +1,63 @@
+package namespaces_informer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	n, client := newTestInformer(
+		t,
+		newTestConfig(),
+		newTestNamespace("review-login", map[string]string{
+			"delete_after": time.Now().UTC().Add(time.Hour).Format(time.RFC3339),
+		}),
+		newTestNamespace("feature-login", nil),
+	)
+
+	newConfig := newTestConfig()
+	newConfig.NsNameDeletionRegexp = "^(review|feature)-"
+	newConfig.DeletionRegexp = regexp.MustCompile(newConfig.NsNameDeletionRegexp)
+	newConfig.Retention = 24 * time.Hour
+	newConfig.Webhook.Enabled = true
+	newConfig.Budget.Enabled = true
+	n.reloadConfig(context.Background(), newConfig)
+
+	config := n.config()
+	if config.NsNameDeletionRegexp != newConfig.NsNameDeletionRegexp || config.Retention != 24*time.Hour {
+		t.Errorf("expected the new regexp and retention, got %q and %s", config.NsNameDeletionRegexp, config.Retention)
+	}
+	if config.Webhook.Enabled || config.Budget.Enabled {
+		t.Error("expected Webhook and Budget.Enabled to be kept until restart")
+	}
+	// The namespace which starts matching the regexp is annotated without waiting for its update.
+	if _, ok := getTestNamespace(t, client, "feature-login").Annotations["delete_after"]; !ok {
+		t.Error("expected feature-login to be annotated after the reload")
+	}
+}
+
+func TestKeepRestartOnly(t *testing.T) {
+	current := newTestConfig()
+	current.Throttle.MinNap = time.Second
+	newConfig := newTestConfig()
+	newConfig.Throttle.MinNap = time.Minute
+	newConfig.Hibernation.Enabled = true
+	newConfig.Hibernation.WorkingHours.NotBefore = "09:00"
+	newConfig.DryRun = true
+
+	changed := keepRestartOnly(&current, &newConfig)
+	if strings.Join(changed, ",") != "Throttle,Hibernation.Enabled" {
+		t.Errorf("expected Throttle and Hibernation.Enabled to be changed, got %v", changed)
+	}
+	if newConfig.Throttle.MinNap != time.Second || newConfig.Hibernation.Enabled {
+		t.Errorf("expected the current Throttle.MinNap and Hibernation.Enabled, got %s and %v",
+			newConfig.Throttle.MinNap, newConfig.Hibernation.Enabled)
+	}
+	// Other settings of a restart-only section are reloaded.
+	if newConfig.Hibernation.WorkingHours.NotBefore != "09:00" || !newConfig.DryRun {
+		t.Error("expected the new working hours and DryRun")
+	}
+}
@@ -0,0 +1,106 @@
+package namespaces_informer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const TEARDOWN_POLL_INTERVAL = 2 * time.Second
+
+// teardownNamespace deletes the configured resource kinds stage by stage before the namespace itself,
+// waiting for every stage to complete. A stage which does not complete in time is logged and skipped,
+// as the namespace deletion will take care of the rest anyway.
+func (n *NsInformer) teardownNamespace(ctx context.Context, ns *corev1.Namespace) error {
+	stages := n.config().Teardown.Stages
+	if len(stages) == 0 {
+		return nil
+	}
+
+	resources, err := n.listNamespacedResources(isListable)
+	if err != nil {
+		return err
+	}
+
+	timeout := n.config().Teardown.StageTimeout
+	deleteOptions := n.deleteOptions()
+
+	for i, stage := range stages {
+		stageResources := resolveResources(stage, resources)
+		n.logger.Info("Tearing down", "namespace", ns.Name, "Stage", i+1, "Resources", stage)
+
+		for _, gvr := range stageResources {
+			err := n.dynamicClient.Resource(gvr).Namespace(ns.Name).DeleteCollection(
+				ctx,
+				deleteOptions,
+				metav1.ListOptions{},
+			)
+			if err != nil {
+				n.logger.Error("Could not delete", "namespace", ns.Name, "Resource", gvr.Resource, "ERROR:", err)
+			}
+		}
+
+		if err := n.waitForStage(ctx, ns.Name, stageResources, timeout); err != nil {
+			n.logger.Warn("Teardown stage is not completed in time", "namespace", ns.Name, "Stage", i+1)
+		}
+	}
+	return nil
+}
+
+func (n *NsInformer) waitForStage(
+	ctx context.Context,
+	namespace string,
+	stageResources []schema.GroupVersionResource,
+	timeout time.Duration,
+) error {
+	return wait.PollImmediateWithContext(
+		ctx,
+		TEARDOWN_POLL_INTERVAL,
+		timeout,
+		func(ctx context.Context) (bool, error) {
+			for _, gvr := range stageResources {
+				list, err := n.dynamicClient.Resource(gvr).Namespace(namespace).List(
+					ctx,
+					metav1.ListOptions{Limit: 1},
+				)
+				if err != nil {
+					return false, nil
+				}
+				if len(list.Items) > 0 {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+	)
+}
+
+// deleteOptions are used both for teardown stages and for the namespace itself.
+func (n *NsInformer) deleteOptions() metav1.DeleteOptions {
+	deleteOptions := metav1.DeleteOptions{}
+	if policy := n.config().Teardown.PropagationPolicy; policy != "" {
+		propagationPolicy := metav1.DeletionPropagation(policy)
+		deleteOptions.PropagationPolicy = &propagationPolicy
+	}
+	return deleteOptions
+}
+
+// resolveResources maps kubectl-like resource names ("ingresses" or "ingresses.networking.k8s.io")
+// to discovered resources.
+func resolveResources(names []string, resources []schema.GroupVersionResource) []schema.GroupVersionResource {
+	resolved := make([]schema.GroupVersionResource, 0, len(names))
+	for _, name := range names {
+		resource, group, _ := strings.Cut(strings.ToLower(name), ".")
+		for _, gvr := range resources {
+			if gvr.Resource == resource && (group == "" || gvr.Group == group) {
+				resolved = append(resolved, gvr)
+			}
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,174 @@
+package namespaces_informer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	TERMINATION_TICK    = time.Minute
+	clearFinalizerPatch = `{"metadata":{"finalizers":null}}`
+)
+
+// stuckNamespace is a namespace which is in Terminating phase for longer than the timeout.
+type stuckNamespace struct {
+	name       string
+	since      time.Time
+	conditions []corev1.NamespaceCondition
+}
+
+// trackTermination remembers a namespace deleted by the reaper, so it is watched until it is gone.
+func (n *NsInformer) trackTermination(name string) {
+	n.terminatingMutex.Lock()
+	defer n.terminatingMutex.Unlock()
+	if _, ok := n.terminating[name]; !ok {
+		n.terminating[name] = time.Now().UTC()
+	}
+}
+
+func (n *NsInformer) forgetTermination(namespace *corev1.Namespace) {
+	n.terminatingMutex.Lock()
+	defer n.terminatingMutex.Unlock()
+	delete(n.terminating, namespace.Name)
+}
+
+// TerminationTicker periodically reports namespaces stuck in Terminating phase and,
+// when it is enabled, removes finalizers which block their termination.
+func (n *NsInformer) TerminationTicker(ctx context.Context) {
+	ticker := time.NewTicker(TERMINATION_TICK)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.logger.Info("Finishig termination ticker...")
+			return
+		case <-ticker.C:
+			n.checkTermination(ctx)
+		}
+	}
+}
+
+func (n *NsInformer) checkTermination(ctx context.Context) {
+	for _, stuck := range n.findStuckNamespaces() {
+		n.reportStuckNamespace(stuck)
+		if n.config().TerminationWatch.RemoveFinalizers {
+			n.removeBlockingFinalizers(ctx, stuck.name)
+		}
+	}
+}
+
+// findStuckNamespaces checks both namespaces deleted by the reaper and watched namespaces
+// which are terminating for another reason, like deletion by hand or before the reaper restart.
+func (n *NsInformer) findStuckNamespaces() []stuckNamespace {
+	namespaces, err := n.nsLister.List(labels.Everything())
+	if err != nil {
+		n.logger.Error("Could not list namespaces to check termination", err)
+		return nil
+	}
+
+	timeout := n.config().TerminationWatch.Timeout
+	timeNow := time.Now().UTC()
+	stuck := make([]stuckNamespace, 0)
+
+	n.terminatingMutex.Lock()
+	defer n.terminatingMutex.Unlock()
+
+	for _, ns := range namespaces {
+		if ns.DeletionTimestamp == nil {
+			continue
+		}
+		_, isTracked := n.terminating[ns.Name]
+		if !isTracked && !n.isSelected(ns) {
+			continue
+		}
+		if ns.DeletionTimestamp.Time.Add(timeout).After(timeNow) {
+			continue
+		}
+
+		stuck = append(stuck, stuckNamespace{
+			name:       ns.Name,
+			since:      ns.DeletionTimestamp.Time,
+			conditions: ns.Status.Conditions,
+		})
+	}
+	return stuck
+}
+
+func (n *NsInformer) reportStuckNamespace(stuck stuckNamespace) {
+	blockers := make([]string, 0)
+	for _, condition := range stuck.conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		blockers = append(blockers, string(condition.Type)+": "+condition.Message)
+	}
+
+	n.logger.Warn(
+		"Namespace is stuck in Terminating",
+		"namespace",
+		stuck.name,
+		"Since",
+		stuck.since.Format(time.RFC3339),
+		"BlockedBy",
+		strings.Join(blockers, "; "),
+	)
+}
+
+// removeBlockingFinalizers clears finalizers of all objects in the namespace which are
+// already being deleted. It is the same as "kubectl patch --type=merge -p '{"metadata":{"finalizers":null}}'"
+// for each of them, so it is opt-in: controllers owning these finalizers do not get a chance to clean up.
+func (n *NsInformer) removeBlockingFinalizers(ctx context.Context, namespace string) {
+	if n.config().DryRun {
+		n.logger.Info("[DRY-RUN] want to remove blocking finalizers", "namespace", namespace)
+		return
+	}
+
+	resources, err := n.listNamespacedResources(isListable)
+	if err != nil {
+		n.logger.Error("Could not discover resources", "namespace", namespace, "ERROR:", err)
+		return
+	}
+
+	for _, gvr := range resources {
+		client := n.dynamicClient.Resource(gvr).Namespace(namespace)
+		list, err := client.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			continue
+		}
+
+		for _, item := range list.Items {
+			if item.GetDeletionTimestamp() == nil || len(item.GetFinalizers()) == 0 {
+				continue
+			}
+			_, err := client.Patch(
+				ctx,
+				item.GetName(),
+				types.MergePatchType,
+				[]byte(clearFinalizerPatch),
+				metav1.PatchOptions{},
+			)
+			if err != nil {
+				n.logger.Error("Could not remove finalizers", "namespace", namespace, "Resource", gvr.Resource, "Name", item.GetName(), "ERROR:", err)
+				continue
+			}
+			n.logger.Warn(
+				"Removed blocking finalizers",
+				"namespace",
+				namespace,
+				"Resource",
+				gvr.Resource,
+				"Name",
+				item.GetName(),
+				"Finalizers",
+				item.GetFinalizers(),
+			)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package namespaces_informer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	TERMINATION_POLL_INTERVAL = 2 * time.Second
+	METRIC_QUERY_TIMEOUT      = 10 * time.Second
+)
+
+// adaptiveThrottle paces deletion batches by observed signals instead of a fixed nap:
+// the nap is doubled on API server pressure (429 responses, which is also how API Priority
+// and Fairness rejects requests) or a metric above the threshold, and halved otherwise.
+// The time spent waiting for the previous batch to terminate counts towards the nap.
+type adaptiveThrottle struct {
+	minNap     time.Duration
+	maxNap     time.Duration
+	currentNap time.Duration
+
+	tooManyRequests atomic.Int64
+	httpClient      *http.Client
+}
+
+type throttledTransport struct {
+	next     http.RoundTripper
+	throttle *adaptiveThrottle
+}
+
+type promQueryResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func newAdaptiveThrottle(minNap time.Duration, maxNap time.Duration) *adaptiveThrottle {
+	return &adaptiveThrottle{
+		minNap:     minNap,
+		maxNap:     maxNap,
+		currentNap: minNap,
+		httpClient: &http.Client{Timeout: METRIC_QUERY_TIMEOUT},
+	}
+}
+
+// setupThrottle replaces the client with the one observing API server responses,
+// so it should be called before informers are created.
+func (n *NsInformer) setupThrottle() error {
+	n.throttle = newAdaptiveThrottle(
+		n.config().Throttle.MinNap,
+		n.config().Throttle.MaxNap,
+	)
+
+	observedConfig := rest.CopyConfig(n.restConfig)
+	observedConfig.Wrap(n.throttle.observe)
+	client, err := kubernetes.NewForConfig(observedConfig)
+	if err != nil {
+		return err
+	}
+	n.client = client
+	return nil
+}
+
+// observe is a rest.Config transport wrapper counting 429 responses of the API server.
+func (t *adaptiveThrottle) observe(rt http.RoundTripper) http.RoundTripper {
+	return &throttledTransport{next: rt, throttle: t}
+}
+
+func (tt *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := tt.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		tt.throttle.tooManyRequests.Add(1)
+	}
+	return resp, err
+}
+
+// nextNap calculates the nap after a batch from the signals collected since the previous batch,
+// and returns what is left of it after the time already spent waiting for the termination.
+func (t *adaptiveThrottle) nextNap(terminationTook time.Duration, isMetricOverThreshold bool) time.Duration {
+	isUnderPressure := t.tooManyRequests.Swap(0) > 0 || isMetricOverThreshold
+
+	next := t.currentNap / 2
+	if isUnderPressure {
+		next = t.currentNap * 2
+		if next == 0 {
+			next = time.Second
+		}
+	}
+	if next < t.minNap {
+		next = t.minNap
+	}
+	if next > t.maxNap {
+		next = t.maxNap
+	}
+
+	t.currentNap = next
+	if terminationTook >= next {
+		return 0
+	}
+	return next - terminationTook
+}
+
+// throttleAfterBatch waits for the batch to terminate and sleeps for the rest of the adaptive nap.
+func (n *NsInformer) throttleAfterBatch(ctx context.Context, batch []*corev1.Namespace) {
+	terminationTook := time.Duration(0)
+	if !n.config().DryRun {
+		terminationTook = n.waitForTermination(ctx, batch, n.throttle.maxNap)
+	}
+
+	isMetricOverThreshold := false
+	if n.config().Throttle.MetricQuery.URL != "" {
+		value, err := n.queryMetric(ctx)
+		if err != nil {
+			n.logger.Warn("Could not query throttling metric", "ERROR:", err)
+		} else {
+			isMetricOverThreshold = value > n.config().Throttle.MetricQuery.Threshold
+		}
+	}
+
+	nap := n.throttle.nextNap(terminationTook, isMetricOverThreshold)
+	n.logger.Info(
+		"Throttling deletions",
+		"TerminationTook",
+		terminationTook.Round(time.Second),
+		"MetricOverThreshold",
+		isMetricOverThreshold,
+		"Nap",
+		nap,
+	)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(nap):
+	}
+}
+
+// waitForTermination returns how long it took for all namespaces of the batch to be gone,
+// or the timeout if some of them are still terminating.
+func (n *NsInformer) waitForTermination(
+	ctx context.Context,
+	batch []*corev1.Namespace,
+	timeout time.Duration,
+) time.Duration {
+	startedAt := time.Now()
+	wait.PollImmediateWithContext(ctx, TERMINATION_POLL_INTERVAL, timeout, func(ctx context.Context) (bool, error) {
+		for _, ns := range batch {
+			_, err := n.client.CoreV1().Namespaces().Get(ctx, ns.Name, metav1.GetOptions{})
+			if !apierrors.IsNotFound(err) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return time.Since(startedAt)
+}
+
+// queryMetric runs an instant query against a Prometheus compatible HTTP API and returns
+// the maximum value of the result.
+func (n *NsInformer) queryMetric(ctx context.Context) (float64, error) {
+	cfg := n.config().Throttle.MetricQuery
+	queryURL := fmt.Sprintf("%s/api/v1/query?query=%s", cfg.URL, url.QueryEscape(cfg.Query))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := n.throttle.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("metric query returned %s", resp.Status)
+	}
+
+	queryResponse := promQueryResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&queryResponse); err != nil {
+		return 0, err
+	}
+
+	values := make([][2]interface{}, 0)
+	switch queryResponse.Data.ResultType {
+	case "scalar":
+		value := [2]interface{}{}
+		if err := json.Unmarshal(queryResponse.Data.Result, &value); err != nil {
+			return 0, err
+		}
+		values = append(values, value)
+	case "vector":
+		vector := make([]struct {
+			Value [2]interface{} `json:"value"`
+		}, 0)
+		if err := json.Unmarshal(queryResponse.Data.Result, &vector); err != nil {
+			return 0, err
+		}
+		for _, sample := range vector {
+			values = append(values, sample.Value)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported metric query result type %q", queryResponse.Data.ResultType)
+	}
+
+	if len(values) == 0 {
+		return 0, errors.New("metric query returned no samples")
+	}
+
+	maxValue := 0.0
+	for i, value := range values {
+		valueString, _ := value[1].(string)
+		parsed, err := strconv.ParseFloat(valueString, 64)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 || parsed > maxValue {
+			maxValue = parsed
+		}
+	}
+	return maxValue, nil
+}
@@ -0,0 +1,179 @@
+package namespaces_informer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextNap(t *testing.T) {
+	tests := []struct {
+		name                  string
+		currentNap            time.Duration
+		tooManyRequests       int64
+		isMetricOverThreshold bool
+		terminationTook       time.Duration
+		nap                   time.Duration
+		currentNapAfter       time.Duration
+	}{
+		{
+			name:            "halved without pressure",
+			currentNap:      time.Minute,
+			nap:             30 * time.Second,
+			currentNapAfter: 30 * time.Second,
+		},
+		{
+			name:            "doubled on too many requests",
+			currentNap:      time.Minute,
+			tooManyRequests: 3,
+			nap:             2 * time.Minute,
+			currentNapAfter: 2 * time.Minute,
+		},
+		{
+			name:                  "doubled on the metric over the threshold",
+			currentNap:            time.Minute,
+			isMetricOverThreshold: true,
+			nap:                   2 * time.Minute,
+			currentNapAfter:       2 * time.Minute,
+		},
+		{
+			name:            "kept within the bounds",
+			currentNap:      4 * time.Minute,
+			tooManyRequests: 1,
+			nap:             5 * time.Minute,
+			currentNapAfter: 5 * time.Minute,
+		},
+		{
+			name:            "termination counts towards the nap",
+			currentNap:      time.Minute,
+			terminationTook: 20 * time.Second,
+			nap:             10 * time.Second,
+			currentNapAfter: 30 * time.Second,
+		},
+		{
+			name:            "no nap after a termination longer than the nap",
+			currentNap:      time.Minute,
+			terminationTook: 2 * time.Minute,
+			nap:             0,
+			currentNapAfter: 30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := newAdaptiveThrottle(10*time.Second, 5*time.Minute)
+			throttle.currentNap = tt.currentNap
+			throttle.tooManyRequests.Store(tt.tooManyRequests)
+
+			if nap := throttle.nextNap(tt.terminationTook, tt.isMetricOverThreshold); nap != tt.nap {
+				t.Errorf("expected the nap %s, got %s", tt.nap, nap)
+			}
+			if throttle.currentNap != tt.currentNapAfter {
+				t.Errorf("expected the current nap %s, got %s", tt.currentNapAfter, throttle.currentNap)
+			}
+			if throttle.tooManyRequests.Load() != 0 {
+				t.Error("expected too many requests to be reset")
+			}
+		})
+	}
+}
+
+func TestObserveTooManyRequests(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	throttle := newAdaptiveThrottle(time.Second, time.Minute)
+	client := &http.Client{Transport: throttle.observe(http.DefaultTransport)}
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusOK, http.StatusTooManyRequests} {
+		status = code
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if count := throttle.tooManyRequests.Load(); count != 2 {
+		t.Errorf("expected 2 too many requests, got %d", count)
+	}
+}
+
+func TestWaitForTermination(t *testing.T) {
+	n, client := newTestInformer(t, newTestConfig(), newTestNamespace("review-login", nil))
+	batch := []*corev1.Namespace{newTestNamespace("review-login", nil), newTestNamespace("review-gone", nil)}
+
+	// A namespace which is still there is waited for until the timeout.
+	if took := n.waitForTermination(context.Background(), batch, 50*time.Millisecond); took < 50*time.Millisecond {
+		t.Errorf("expected to wait for the timeout, took %s", took)
+	}
+
+	if err := client.CoreV1().Namespaces().Delete(context.Background(), "review-login", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if took := n.waitForTermination(context.Background(), batch, time.Minute); took >= TERMINATION_POLL_INTERVAL {
+		t.Errorf("expected the terminated batch not to be waited for, took %s", took)
+	}
+}
+
+func TestQueryMetric(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		value    float64
+		isError  bool
+	}{
+		{
+			name:     "maximum of the vector",
+			response: `{"status":"success","data":{"resultType":"vector","result":[{"value":[1700000000,"0.4"]},{"value":[1700000000,"0.9"]}]}}`,
+			value:    0.9,
+		},
+		{
+			name:     "scalar",
+			response: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"12"]}}`,
+			value:    12,
+		},
+		{
+			name:     "no samples",
+			response: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			isError:  true,
+		},
+		{
+			name:     "unsupported result type",
+			response: `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			isError:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/query" || r.URL.Query().Get("query") != "apiserver_load" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			config := newTestConfig()
+			config.Throttle.MetricQuery.URL = server.URL
+			config.Throttle.MetricQuery.Query = "apiserver_load"
+			n, _ := newTestInformer(t, config)
+			n.throttle = newAdaptiveThrottle(time.Second, time.Minute)
+
+			value, err := n.queryMetric(context.Background())
+			if (err != nil) != tt.isError {
+				t.Fatalf("expected error %v, got %v", tt.isError, err)
+			}
+			if value != tt.value {
+				t.Errorf("expected %v, got %v", tt.value, value)
+			}
+		})
+	}
+}
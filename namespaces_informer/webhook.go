@@ -0,0 +1,283 @@
+package namespaces_informer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	WEBHOOK_PATH           = "/webhook"
+	WEBHOOK_MAX_BODY_BYTES = 1 << 20
+	gitZeroSha             = "0000000000000000000000000000000000000000"
+)
+
+var errWebhookSignatureInvalid = errors.New("webhook signature invalid")
+
+// forgeEvent is the forge-agnostic part of an incoming webhook payload we care about.
+// Either Branch or PullRequest (or both) is set when the event means that the review environment is gone.
+type forgeEvent struct {
+	Branch      string
+	PullRequest string
+}
+
+type githubPayload struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	Ref         string `json:"ref"`
+	RefType     string `json:"ref_type"`
+	PullRequest struct {
+		Number int `json:"number"`
+		Head   struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+type gitlabPayload struct {
+	ObjectKind       string `json:"object_kind"`
+	Ref              string `json:"ref"`
+	After            string `json:"after"`
+	ObjectAttributes struct {
+		Iid          int    `json:"iid"`
+		SourceBranch string `json:"source_branch"`
+		State        string `json:"state"`
+		Action       string `json:"action"`
+	} `json:"object_attributes"`
+}
+
+func (n *NsInformer) serveWebhooks(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(WEBHOOK_PATH, n.handleWebhook(ctx))
+
+	server := &http.Server{
+		Addr:              n.config().Webhook.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	n.logger.Info("Listening for forge webhooks", "Address", server.Addr, "Path", WEBHOOK_PATH)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		n.logger.Error("Webhook receiver stopped", "ERROR:", err)
+	}
+}
+
+func (n *NsInformer) handleWebhook(ctx context.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, WEBHOOK_MAX_BODY_BYTES))
+		if err != nil {
+			http.Error(w, "could not read body", http.StatusBadRequest)
+			return
+		}
+
+		event, err := n.parseForgeEvent(r.Header, body)
+		if errors.Is(err, errWebhookSignatureInvalid) {
+			n.logger.Warn("Rejected webhook with invalid signature", "RemoteAddr", r.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if event == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		expired, err := n.expireNamespacesForEvent(ctx, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"expired": expired})
+	}
+}
+
+// parseForgeEvent verifies the request secret and returns nil event for payloads which
+// do not mean that a branch or a pull request is gone.
+func (n *NsInformer) parseForgeEvent(header http.Header, body []byte) (*forgeEvent, error) {
+	githubEvent := header.Get("X-GitHub-Event")
+	if githubEvent == "" {
+		githubEvent = header.Get("X-Gitea-Event")
+	}
+
+	switch {
+	case githubEvent != "":
+		if !n.isGithubSignatureValid(header, body) {
+			return nil, errWebhookSignatureInvalid
+		}
+		return parseGithubEvent(githubEvent, body)
+	case header.Get("X-Gitlab-Event") != "":
+		token := header.Get("X-Gitlab-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(n.config().Webhook.Secret)) != 1 {
+			return nil, errWebhookSignatureInvalid
+		}
+		return parseGitlabEvent(body)
+	}
+	return nil, errors.New("unknown webhook source")
+}
+
+func (n *NsInformer) isGithubSignatureValid(header http.Header, body []byte) bool {
+	signature := strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if signature == "" {
+		// Gitea sends the bare hex digest in its own header.
+		signature = header.Get("X-Gitea-Signature")
+	}
+	received, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(n.config().Webhook.Secret))
+	mac.Write(body)
+	return hmac.Equal(received, mac.Sum(nil))
+}
+
+func parseGithubEvent(eventType string, body []byte) (*forgeEvent, error) {
+	payload := githubPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	switch eventType {
+	case "pull_request":
+		if payload.Action != "closed" {
+			return nil, nil
+		}
+		number := payload.PullRequest.Number
+		if number == 0 {
+			number = payload.Number
+		}
+		return &forgeEvent{
+			Branch:      payload.PullRequest.Head.Ref,
+			PullRequest: strconv.Itoa(number),
+		}, nil
+	case "delete":
+		if payload.RefType != "branch" {
+			return nil, nil
+		}
+		return &forgeEvent{Branch: payload.Ref}, nil
+	}
+	return nil, nil
+}
+
+func parseGitlabEvent(body []byte) (*forgeEvent, error) {
+	payload := gitlabPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	switch payload.ObjectKind {
+	case "merge_request":
+		attrs := payload.ObjectAttributes
+		if attrs.State != "merged" && attrs.State != "closed" {
+			return nil, nil
+		}
+		return &forgeEvent{
+			Branch:      attrs.SourceBranch,
+			PullRequest: strconv.Itoa(attrs.Iid),
+		}, nil
+	case "push":
+		if payload.After != gitZeroSha || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+			return nil, nil
+		}
+		return &forgeEvent{Branch: strings.TrimPrefix(payload.Ref, "refs/heads/")}, nil
+	}
+	return nil, nil
+}
+
+func (n *NsInformer) expireNamespacesForEvent(
+	ctx context.Context,
+	event *forgeEvent,
+) ([]string, error) {
+	namespaces, err := n.nsLister.List(labels.Everything())
+	if err != nil {
+		return nil, errors.New("could not list namespaces")
+	}
+
+	expired := make([]string, 0)
+
+	for _, ns := range namespaces {
+		if !n.isWatched(ns) || !n.isNsMatchingEvent(ns, event) {
+			continue
+		}
+		if n.config().DryRun {
+			n.logger.Info(
+				"[DRY-RUN] want to expire by forge webhook",
+				"namespace",
+				ns.Name,
+				"Branch",
+				event.Branch,
+				"PullRequest",
+				event.PullRequest,
+			)
+			expired = append(expired, ns.Name)
+			continue
+		}
+		if err := n.expireNamespace(ctx, ns, "webhook"); err != nil {
+			return expired, err
+		}
+		n.logger.Info(
+			"Marked as expired by forge webhook",
+			"NsName",
+			ns.Name,
+			"Branch",
+			event.Branch,
+			"PullRequest",
+			event.PullRequest,
+		)
+		expired = append(expired, ns.Name)
+	}
+
+	return expired, nil
+}
+
+func (n *NsInformer) isNsMatchingEvent(ns *corev1.Namespace, event *forgeEvent) bool {
+	if event.Branch != "" && nsMetaValue(ns, n.config().Webhook.BranchKey) == event.Branch {
+		return true
+	}
+	if event.PullRequest != "" &&
+		nsMetaValue(ns, n.config().Webhook.PullRequestKey) == event.PullRequest {
+		return true
+	}
+	return false
+}
+
+// nsMetaValue looks the key up in namespace labels first and annotations second,
+// so the branch or pull request reference might be stored in either of them.
+func nsMetaValue(ns *corev1.Namespace, key string) string {
+	if key == "" {
+		return ""
+	}
+	if value, ok := ns.Labels[key]; ok {
+		return value
+	}
+	return ns.Annotations[key]
+}
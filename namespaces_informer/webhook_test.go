@@ -0,0 +1,284 @@
+package namespaces_informer
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testWebhookSecret = "s3cr3t"
+
+func githubSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookRequest struct {
+	header map[string]string
+	body   string
+}
+
+func githubRequest(event, secret, body string) webhookRequest {
+	return webhookRequest{
+		header: map[string]string{
+			"X-GitHub-Event":      event,
+			"X-Hub-Signature-256": "sha256=" + githubSignature(secret, body),
+		},
+		body: body,
+	}
+}
+
+func giteaRequest(event, secret, body string) webhookRequest {
+	return webhookRequest{
+		header: map[string]string{
+			"X-Gitea-Event":     event,
+			"X-Gitea-Signature": githubSignature(secret, body),
+		},
+		body: body,
+	}
+}
+
+func gitlabRequest(event, token, body string) webhookRequest {
+	return webhookRequest{
+		header: map[string]string{
+			"X-Gitlab-Event": event,
+			"X-Gitlab-Token": token,
+		},
+		body: body,
+	}
+}
+
+const (
+	githubMergedPayload = `{"action":"closed","number":42,"pull_request":{"number":42,"merged":true,"head":{"ref":"feature/login"}}}`
+	githubOpenedPayload = `{"action":"opened","number":42,"pull_request":{"number":42,"head":{"ref":"feature/login"}}}`
+	githubDeletePayload = `{"ref":"feature/login","ref_type":"branch"}`
+	giteaClosedPayload  = `{"action":"closed","number":7,"pull_request":{"number":7,"merged":false,"head":{"ref":"fix/typo"}}}`
+	gitlabMergedPayload = `{"object_kind":"merge_request","object_attributes":{"iid":13,"source_branch":"feature/login","state":"merged","action":"merge"}}`
+	gitlabClosedPayload = `{"object_kind":"merge_request","object_attributes":{"iid":13,"source_branch":"feature/login","state":"closed","action":"close"}}`
+	gitlabOpenedPayload = `{"object_kind":"merge_request","object_attributes":{"iid":13,"source_branch":"feature/login","state":"opened","action":"open"}}`
+	gitlabDeletePayload = `{"object_kind":"push","ref":"refs/heads/feature/login","after":"0000000000000000000000000000000000000000"}`
+)
+
+func TestParseForgeEvent(t *testing.T) {
+	config := newTestConfig()
+	config.Webhook.Secret = testWebhookSecret
+	n, _ := newTestInformer(t, config)
+
+	tests := []struct {
+		name    string
+		request webhookRequest
+		event   *forgeEvent
+		err     error
+	}{
+		{
+			name:    "github merged pull request",
+			request: githubRequest("pull_request", testWebhookSecret, githubMergedPayload),
+			event:   &forgeEvent{Branch: "feature/login", PullRequest: "42"},
+		},
+		{
+			name:    "github opened pull request",
+			request: githubRequest("pull_request", testWebhookSecret, githubOpenedPayload),
+		},
+		{
+			name:    "github deleted branch",
+			request: githubRequest("delete", testWebhookSecret, githubDeletePayload),
+			event:   &forgeEvent{Branch: "feature/login"},
+		},
+		{
+			name:    "github bad signature",
+			request: githubRequest("pull_request", "wrong", githubMergedPayload),
+			err:     errWebhookSignatureInvalid,
+		},
+		{
+			name: "github missing signature",
+			request: webhookRequest{
+				header: map[string]string{"X-GitHub-Event": "pull_request"},
+				body:   githubMergedPayload,
+			},
+			err: errWebhookSignatureInvalid,
+		},
+		{
+			name:    "gitea closed pull request",
+			request: giteaRequest("pull_request", testWebhookSecret, giteaClosedPayload),
+			event:   &forgeEvent{Branch: "fix/typo", PullRequest: "7"},
+		},
+		{
+			name:    "gitea bad signature",
+			request: giteaRequest("pull_request", "wrong", giteaClosedPayload),
+			err:     errWebhookSignatureInvalid,
+		},
+		{
+			name:    "gitlab merged merge request",
+			request: gitlabRequest("Merge Request Hook", testWebhookSecret, gitlabMergedPayload),
+			event:   &forgeEvent{Branch: "feature/login", PullRequest: "13"},
+		},
+		{
+			name:    "gitlab closed merge request",
+			request: gitlabRequest("Merge Request Hook", testWebhookSecret, gitlabClosedPayload),
+			event:   &forgeEvent{Branch: "feature/login", PullRequest: "13"},
+		},
+		{
+			name:    "gitlab opened merge request",
+			request: gitlabRequest("Merge Request Hook", testWebhookSecret, gitlabOpenedPayload),
+		},
+		{
+			name:    "gitlab deleted branch",
+			request: gitlabRequest("Push Hook", testWebhookSecret, gitlabDeletePayload),
+			event:   &forgeEvent{Branch: "feature/login"},
+		},
+		{
+			name:    "gitlab bad token",
+			request: gitlabRequest("Merge Request Hook", "wrong", gitlabMergedPayload),
+			err:     errWebhookSignatureInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.request.header {
+				header.Set(key, value)
+			}
+			event, err := n.parseForgeEvent(header, []byte(tt.request.body))
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(event, tt.event) {
+				t.Errorf("expected event %+v, got %+v", tt.event, event)
+			}
+		})
+	}
+}
+
+func TestNsMetaValue(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"branch": "from-label", "empty": ""},
+			Annotations: map[string]string{"branch": "from-annotation", "empty": "annotation", "pr": "42"},
+		},
+	}
+
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{key: "branch", value: "from-label"},
+		{key: "empty", value: ""},
+		{key: "pr", value: "42"},
+		{key: "missing", value: ""},
+		{key: "", value: ""},
+	}
+	for _, tt := range tests {
+		if value := nsMetaValue(ns, tt.key); value != tt.value {
+			t.Errorf("nsMetaValue(%q) = %q, expected %q", tt.key, value, tt.value)
+		}
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	namespaces := []*corev1.Namespace{
+		newTestNamespace("review-login", map[string]string{"review-reaper/source-ref": "feature/login"}),
+		newTestNamespace("review-pr-13", map[string]string{"review-reaper/pull-request": "13"}),
+		newTestNamespace("review-other", map[string]string{"review-reaper/source-ref": "feature/other"}),
+		newTestNamespace("review-protected", map[string]string{
+			"review-reaper/source-ref": "feature/login",
+			"review-reaper-protected":  "true",
+		}),
+		newTestNamespace("production", map[string]string{"review-reaper/source-ref": "feature/login"}),
+	}
+	namespaces[1].Labels = map[string]string{"review-reaper/source-ref": "feature/mr"}
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		request webhookRequest
+		status  int
+		expired []string
+	}{
+		{
+			name:    "github merged",
+			request: githubRequest("pull_request", testWebhookSecret, githubMergedPayload),
+			status:  http.StatusOK,
+			expired: []string{"review-login"},
+		},
+		{
+			name:    "gitlab merged by pull request number",
+			request: gitlabRequest("Merge Request Hook", testWebhookSecret, gitlabMergedPayload),
+			status:  http.StatusOK,
+			expired: []string{"review-login", "review-pr-13"},
+		},
+		{
+			name:    "dry run",
+			dryRun:  true,
+			request: githubRequest("pull_request", testWebhookSecret, githubMergedPayload),
+			status:  http.StatusOK,
+			expired: []string{"review-login"},
+		},
+		{
+			name:    "bad signature",
+			request: githubRequest("pull_request", "wrong", githubMergedPayload),
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "not a closing event",
+			request: githubRequest("pull_request", testWebhookSecret, githubOpenedPayload),
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "unknown source",
+			request: webhookRequest{body: githubMergedPayload},
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Webhook.Secret = testWebhookSecret
+			config.DryRun = tt.dryRun
+			n, client := newTestInformer(t, config, namespaces...)
+
+			request := httptest.NewRequest(http.MethodPost, WEBHOOK_PATH, strings.NewReader(tt.request.body))
+			for key, value := range tt.request.header {
+				request.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+			n.handleWebhook(context.Background())(recorder, request)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, recorder.Code, recorder.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			response := map[string][]string{}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(response["expired"])
+			if !reflect.DeepEqual(response["expired"], tt.expired) {
+				t.Errorf("expected expired %v, got %v", tt.expired, response["expired"])
+			}
+
+			for _, ns := range namespaces {
+				expiredBy := getTestNamespace(t, client, ns.Name).Annotations[config.NsExpiredByAnnotation]
+				isExpected := !tt.dryRun && utils.IsContains(tt.expired, ns.Name)
+				if isExpected != (expiredBy == "webhook") {
+					t.Errorf("namespace %s expired by %q, expected to be expired: %v", ns.Name, expiredBy, isExpected)
+				}
+			}
+		})
+	}
+}
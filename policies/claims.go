@@ -0,0 +1,185 @@
+package policies
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	CLAIM_KIND     = "ReaperClaim"
+	CLAIM_RESOURCE = "reaperclaims"
+)
+
+var ClaimGroupVersionResource = schema.GroupVersionResource{Group: GROUP, Version: VERSION, Resource: CLAIM_RESOURCE}
+
+// ReaperClaim opts the namespace it is created in into the reaper, under the limits of the governing policy.
+type ReaperClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ReaperClaimSpec   `json:"spec"`
+	Status ReaperClaimStatus `json:"status,omitempty"`
+}
+
+type ReaperClaimSpec struct {
+	// TTL is counted from the namespace creation like Retention, and is capped by the governing retention.
+	TTL       string `json:"ttl,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Contact   string `json:"contact,omitempty"`
+	SourceRef string `json:"sourceRef,omitempty"`
+}
+
+type ReaperClaimStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	Phase              string `json:"phase,omitempty"`
+	DeleteAfter        string `json:"deleteAfter,omitempty"`
+	Retention          string `json:"retention,omitempty"`
+	// Policy is the ReaperPolicy governing the namespace, the config governs it if empty.
+	Policy  string `json:"policy,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type ClaimClient struct {
+	resource dynamic.NamespaceableResourceInterface
+}
+
+func NewClaimClient(dynamicClient dynamic.Interface) *ClaimClient {
+	return &ClaimClient{resource: dynamicClient.Resource(ClaimGroupVersionResource)}
+}
+
+// List returns claims of all namespaces sorted by namespace and name.
+func (c *ClaimClient) List(ctx context.Context) ([]*ReaperClaim, error) {
+	list, err := c.resource.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	claims := make([]*ReaperClaim, 0, len(list.Items))
+	for i := range list.Items {
+		claim, err := ClaimFromUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	sortClaims(claims)
+	return claims, nil
+}
+
+// Snapshot returns a lister of the claims as they are now, for commands which do not start the informer.
+func (c *ClaimClient) Snapshot(ctx context.Context) (*ClaimLister, error) {
+	list, err := c.resource.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for i := range list.Items {
+		if err := indexer.Add(&list.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &ClaimLister{indexer: indexer}, nil
+}
+
+// UpdateStatus replaces the status of the claim the same way Client.UpdateStatus does.
+func (c *ClaimClient) UpdateStatus(ctx context.Context, namespace string, name string, status ReaperClaimStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"observedGeneration": status.ObservedGeneration,
+			"phase":              nullIfEmpty(status.Phase),
+			"deleteAfter":        nullIfEmpty(status.DeleteAfter),
+			"retention":          nullIfEmpty(status.Retention),
+			"policy":             nullIfEmpty(status.Policy),
+			"message":            nullIfEmpty(status.Message),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.resource.Namespace(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
+// ClaimInformer watches claims of all namespaces and indexes them by namespace.
+type ClaimInformer struct {
+	informer cache.SharedIndexInformer
+}
+
+func NewClaimInformer(dynamicClient dynamic.Interface, resync time.Duration) *ClaimInformer {
+	informer := dynamicinformer.NewFilteredDynamicInformer(
+		dynamicClient,
+		ClaimGroupVersionResource,
+		metav1.NamespaceAll,
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		nil,
+	)
+	return &ClaimInformer{informer: informer.Informer()}
+}
+
+func (i *ClaimInformer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *ClaimInformer) Lister() *ClaimLister {
+	return &ClaimLister{indexer: i.informer.GetIndexer()}
+}
+
+// ClaimLister reads claims from the informer cache, skipping objects which do not decode.
+type ClaimLister struct {
+	indexer cache.Indexer
+}
+
+func (l *ClaimLister) List() []*ReaperClaim {
+	return decodeClaims(l.indexer.List())
+}
+
+func (l *ClaimLister) ListInNamespace(namespace string) []*ReaperClaim {
+	items, err := l.indexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil
+	}
+	return decodeClaims(items)
+}
+
+func decodeClaims(items []interface{}) []*ReaperClaim {
+	claims := make([]*ReaperClaim, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if claim, err := ClaimFromUnstructured(obj); err == nil {
+			claims = append(claims, claim)
+		}
+	}
+	sortClaims(claims)
+	return claims
+}
+
+func ClaimFromUnstructured(obj *unstructured.Unstructured) (*ReaperClaim, error) {
+	claim := &ReaperClaim{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+func sortClaims(claims []*ReaperClaim) {
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].Namespace != claims[j].Namespace {
+			return claims[i].Namespace < claims[j].Namespace
+		}
+		return claims[i].Name < claims[j].Name
+	})
+}
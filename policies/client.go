@@ -0,0 +1,89 @@
+// Package policies is the typed client and informer of the ReaperPolicy and ReaperClaim custom resources.
+// They are thin wrappers of the dynamic ones, so no generated clientset is needed.
+package policies
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+type Client struct {
+	resource dynamic.NamespaceableResourceInterface
+}
+
+func NewClient(dynamicClient dynamic.Interface) *Client {
+	return &Client{resource: dynamicClient.Resource(GroupVersionResource)}
+}
+
+func (c *Client) Get(ctx context.Context, name string) (*ReaperPolicy, error) {
+	obj, err := c.resource.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return FromUnstructured(obj)
+}
+
+// List returns all policies sorted by name.
+func (c *Client) List(ctx context.Context) ([]*ReaperPolicy, error) {
+	list, err := c.resource.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]*ReaperPolicy, 0, len(list.Items))
+	for i := range list.Items {
+		policy, err := FromUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	sortByName(policies)
+	return policies, nil
+}
+
+// UpdateStatus replaces the status of the policy with a merge patch of the status subresource,
+// so it does not conflict with spec changes. Empty fields are patched with null to be removed.
+func (c *Client) UpdateStatus(ctx context.Context, name string, status ReaperPolicyStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"observedGeneration":    status.ObservedGeneration,
+			"matchedNamespaces":     status.MatchedNamespaces,
+			"nextDeletion":          nullIfEmpty(status.NextDeletion),
+			"nextDeletionNamespace": nullIfEmpty(status.NextDeletionNamespace),
+			"error":                 nullIfEmpty(status.Error),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.resource.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func FromUnstructured(obj *unstructured.Unstructured) (*ReaperPolicy, error) {
+	policy := &ReaperPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func sortByName(policies []*ReaperPolicy) {
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+}
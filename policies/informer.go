@@ -0,0 +1,59 @@
+package policies
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Informer watches policies and keeps them in a local cache.
+type Informer struct {
+	informer cache.SharedIndexInformer
+}
+
+func NewInformer(dynamicClient dynamic.Interface, resync time.Duration) *Informer {
+	informer := dynamicinformer.NewFilteredDynamicInformer(
+		dynamicClient,
+		GroupVersionResource,
+		metav1.NamespaceAll,
+		resync,
+		cache.Indexers{},
+		nil,
+	)
+	return &Informer{informer: informer.Informer()}
+}
+
+func (i *Informer) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *Informer) Lister() *Lister {
+	return &Lister{store: i.informer.GetStore()}
+}
+
+// Lister reads policies from the informer cache.
+type Lister struct {
+	store cache.Store
+}
+
+// List returns all cached policies sorted by name. Objects which do not decode are skipped,
+// the API server validates them against the CRD schema anyway.
+func (l *Lister) List() []*ReaperPolicy {
+	items := l.store.List()
+	policies := make([]*ReaperPolicy, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if policy, err := FromUnstructured(obj); err == nil {
+			policies = append(policies, policy)
+		}
+	}
+	sortByName(policies)
+	return policies
+}
@@ -0,0 +1,64 @@
+package policies
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GROUP    = "reviewreaper.io"
+	VERSION  = "v1alpha1"
+	KIND     = "ReaperPolicy"
+	RESOURCE = "reaperpolicies"
+)
+
+var GroupVersionResource = schema.GroupVersionResource{Group: GROUP, Version: VERSION, Resource: RESOURCE}
+
+// ReaperPolicy is a cluster-scoped set of deletion rules for the namespaces it selects.
+// Unset fields fall back to the config.
+type ReaperPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ReaperPolicySpec   `json:"spec"`
+	Status ReaperPolicyStatus `json:"status,omitempty"`
+}
+
+type ReaperPolicySpec struct {
+	// NamespaceSelector and NameRegexp both have to match, a policy without either selects nothing.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	NameRegexp        string                `json:"nameRegexp,omitempty"`
+	// Priority decides between policies selecting the same namespace, the highest wins.
+	Priority       int             `json:"priority,omitempty"`
+	Retention      string          `json:"retention,omitempty"`
+	DeletionWindow *DeletionWindow `json:"deletionWindow,omitempty"`
+	Helm           *HelmOptions    `json:"helm,omitempty"`
+	Notifications  *Notifications  `json:"notifications,omitempty"`
+}
+
+type DeletionWindow struct {
+	NotBefore string   `json:"notBefore"`
+	NotAfter  string   `json:"notAfter"`
+	WeekDays  []string `json:"weekDays,omitempty"`
+}
+
+type HelmOptions struct {
+	PostponeByDeploy  *bool `json:"postponeByDeploy,omitempty"`
+	UninstallReleases *bool `json:"uninstallReleases,omitempty"`
+}
+
+// Notifications are Events recorded in the governed namespaces, so their owners see them
+// with "kubectl get events".
+type Notifications struct {
+	Events bool `json:"events,omitempty"`
+}
+
+type ReaperPolicyStatus struct {
+	ObservedGeneration int64    `json:"observedGeneration,omitempty"`
+	MatchedNamespaces  []string `json:"matchedNamespaces,omitempty"`
+	// NextDeletion is the earliest deletion timestamp of the matched namespaces.
+	NextDeletion          string `json:"nextDeletion,omitempty"`
+	NextDeletionNamespace string `json:"nextDeletionNamespace,omitempty"`
+	// Error is set when the spec is invalid, the policy selects nothing then.
+	Error string `json:"error,omitempty"`
+}
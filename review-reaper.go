@@ -2,13 +2,18 @@ package main
 
 import (
 	"NaNameUz3r/ReviewReaper/logs"
+	"NaNameUz3r/ReviewReaper/metrics"
 	"NaNameUz3r/ReviewReaper/namespaces_informer"
 	"NaNameUz3r/ReviewReaper/utils"
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,26 +22,169 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 )
 
+//go:generate sh -c "go run . config-schema > config.schema.json"
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+type cliFlags struct {
+	configPath  string
+	kubeConfig  string
+	kubeContext string
+}
+
 func main() {
-	appConfig, err := utils.LoadConfig()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := rootCommand().ExecuteContext(ctx); err != nil {
+		cancel()
+		os.Exit(1)
+	}
+}
+
+func rootCommand() *cobra.Command {
+	flags := &cliFlags{}
+
+	runCommand := &cobra.Command{
+		Use:   "run",
+		Short: "Run the namespace controller",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), flags)
+		},
+	}
+
+	root := &cobra.Command{
+		Use:          "review-reaper",
+		Short:        "ReviewReaper deletes forgotten review environment namespaces",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		// The controller is run by default, so the image keeps working without arguments.
+		RunE: runCommand.RunE,
+	}
+
+	root.PersistentFlags().StringVarP(&flags.configPath, "config", "c", "", "Config file, looked up as config.yaml in ., /app and /etc/app by default")
+	root.PersistentFlags().StringVar(&flags.kubeConfig, "kubeconfig", "", "Path to the kubeconfig file, in-cluster config is used by default")
+	root.PersistentFlags().StringVar(&flags.kubeContext, "context", "", "The name of the kubeconfig context to use")
+	root.PersistentFlags().String("log-level", "", "Log level, overrides LogLevel of the config")
+	root.PersistentFlags().Bool("dry-run", false, "Only log intentions, overrides DryRun of the config")
+	root.PersistentFlags().Bool("once", false, "Perform a single reconciliation and exit, overrides RunOnce of the config")
+	utils.BindFlag("LogLevel", root.PersistentFlags().Lookup("log-level"))
+	utils.BindFlag("DryRun", root.PersistentFlags().Lookup("dry-run"))
+	utils.BindFlag("RunOnce", root.PersistentFlags().Lookup("once"))
+
+	root.AddCommand(
+		runCommand,
+		&cobra.Command{
+			Use:   "plan",
+			Short: "Print what the next maintenance window will do, without starting the controller",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return plan(cmd.Context(), flags)
+			},
+		},
+		&cobra.Command{
+			Use:   "validate-config",
+			Short: "Load and validate the config, exit with non-zero code if it is invalid",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if _, err := loadConfig(flags); err != nil {
+					return fmt.Errorf("config is invalid:\n%w", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), "Config is valid")
+				return nil
+			},
+		},
+		migrateConfigCommand(flags),
+		&cobra.Command{
+			Use:   "config-schema",
+			Short: "Print the JSON Schema of the config file",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				schema, err := utils.ConfigSchema()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(schema))
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "version",
+			Short: "Print the version",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				fmt.Fprintln(cmd.OutOrStdout(), version)
+			},
+		},
+	)
+	return root
+}
+
+// migrateConfigCommand rewrites a legacy config file to the current apiVersion.
+func migrateConfigCommand(flags *cliFlags) *cobra.Command {
+	toStdout := false
+	command := &cobra.Command{
+		Use:   "migrate-config",
+		Short: "Rewrite a legacy config file to " + utils.CONFIG_API_VERSION + ", comments are not preserved",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := utils.ResolveConfigFile(flags.configPath)
+			if err != nil {
+				return err
+			}
+			migrated, isLegacy, err := utils.MigrateConfigFile(path)
+			if err != nil {
+				return err
+			}
+			if !isLegacy {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s is already %s\n", path, utils.CONFIG_API_VERSION)
+				return nil
+			}
+			if toStdout {
+				_, err := cmd.OutOrStdout().Write(migrated)
+				return err
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Migrated %s to %s\n", path, utils.CONFIG_API_VERSION)
+
+			if _, err := utils.LoadConfigFile(path); err != nil {
+				return fmt.Errorf("migrated config is invalid:\n%w", err)
+			}
+			return nil
+		},
+	}
+	command.Flags().BoolVar(&toStdout, "stdout", false, "Print the migrated config instead of rewriting the file")
+	return command
+}
+
+func run(ctx context.Context, flags *cliFlags) error {
+	appConfig, err := loadConfig(flags)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	logger := logs.NewLogger(appConfig)
 	logs.StartUp(appConfig, logger)
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
-
-	clusterConfig, err := setClusterConfig()
+	clusterConfig, err := setClusterConfig(flags)
 	if err != nil {
 		logger.Error("Could not get ClusterConfig", err)
+		return err
 	}
 
 	clusterClient, err := kubernetes.NewForConfig(clusterConfig)
 	if err != nil {
 		logger.Error("Could not make ClientSet", err)
+		return err
 	}
 
 	newInformer := namespaces_informer.NewNsInformer(
@@ -45,23 +193,109 @@ func main() {
 		logger,
 		appConfig,
 	)
+
+	if appConfig.RunOnce {
+		summary, err := newInformer.RunOnce(ctx)
+		logger.Info(
+			"One-shot run finished",
+			"Watched",
+			summary.Watched,
+			"Unprotected",
+			summary.Unprotected,
+			"Annotated",
+			summary.Annotated,
+			"Postponed",
+			summary.Postponed,
+			"Expired",
+			summary.Expired,
+			"Deleted",
+			summary.Deleted,
+			"InWindow",
+			summary.InWindow,
+			"DryRun",
+			appConfig.DryRun,
+		)
+		if err != nil {
+			logger.Error("One-shot run failed", "ERROR:", err)
+		}
+		return err
+	}
+
+	if appConfig.Metrics.Enabled {
+		go metrics.Serve(ctx, appConfig.Metrics.ListenAddress, logger)
+	}
+
 	if err := newInformer.Run(ctx); err != nil {
 		logger.Error("Could not start informer", err)
+		return err
 	}
 
 	logger.Info("Successfully started the reconciliation loop.")
 
 	<-ctx.Done()
+	return nil
 }
 
-func setClusterConfig() (*rest.Config, error) {
-	config, err := rest.InClusterConfig()
+// plan prints what the next maintenance window will do without starting the informer.
+func plan(ctx context.Context, flags *cliFlags) error {
+	appConfig, err := loadConfig(flags)
+	if err != nil {
+		return err
+	}
+	logger := logs.NewLogger(appConfig)
 
-	if kubeConfig := os.Getenv("KUBECONFIG"); kubeConfig != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeConfig)
+	clusterConfig, err := setClusterConfig(flags)
+	if err != nil {
+		logger.Error("Could not get ClusterConfig", err)
+		return err
 	}
+	clusterClient, err := kubernetes.NewForConfig(clusterConfig)
+	if err != nil {
+		logger.Error("Could not make ClientSet", err)
+		return err
+	}
+
+	list, err := clusterClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, err
+		logger.Error("Could not list namespaces", err)
+		return err
 	}
-	return config, nil
+	namespaces := make([]*corev1.Namespace, 0, len(list.Items))
+	for i := range list.Items {
+		namespaces = append(namespaces, &list.Items[i])
+	}
+
+	informer := namespaces_informer.NewNsInformer(clusterConfig, clusterClient, logger, appConfig)
+	if err := informer.LoadPolicies(ctx); err != nil {
+		logger.Error("Could not load policies", err)
+		return err
+	}
+	informer.Plan(namespaces).Print(os.Stdout)
+	return nil
+}
+
+func loadConfig(flags *cliFlags) (utils.Config, error) {
+	if flags.configPath != "" {
+		return utils.LoadConfigFile(flags.configPath)
+	}
+	return utils.LoadConfig()
+}
+
+// setClusterConfig uses the kubeconfig from the flags or KUBECONFIG env and falls back to in-cluster config.
+// The flags are exported to the env, so Helm actions use the same cluster.
+func setClusterConfig(flags *cliFlags) (*rest.Config, error) {
+	if flags.kubeConfig != "" {
+		os.Setenv("KUBECONFIG", flags.kubeConfig)
+	}
+	if flags.kubeContext != "" {
+		os.Setenv("HELM_KUBECONTEXT", flags.kubeContext)
+	}
+
+	if os.Getenv("KUBECONFIG") == "" && flags.kubeContext == "" {
+		return rest.InClusterConfig()
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: flags.kubeContext}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 }
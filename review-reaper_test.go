@@ -0,0 +1,75 @@
+package main
+
+import (
+	"NaNameUz3r/ReviewReaper/utils"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestMigrateConfigCommand(t *testing.T) {
+	legacy, err := os.ReadFile("testdata/legacy-config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("testdata/legacy-config.migrated.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, legacy, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	command := migrateConfigCommand(&cliFlags{configPath: path})
+	command.SetArgs([]string{"--stdout"})
+	command.SetOut(&stdout)
+	if err := command.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != string(expected) {
+		t.Errorf("expected migrated config\n%s\ngot\n%s", expected, stdout.String())
+	}
+	if unchanged, _ := os.ReadFile(path); !bytes.Equal(unchanged, legacy) {
+		t.Error("expected --stdout to leave the file as is")
+	}
+
+	command = migrateConfigCommand(&cliFlags{configPath: path})
+	command.SetArgs([]string{})
+	command.SetOut(&bytes.Buffer{})
+	if err := command.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, _ := os.ReadFile(path); !bytes.Equal(migrated, expected) {
+		t.Errorf("expected the file to be rewritten\n%s\ngot\n%s", expected, migrated)
+	}
+
+	config, err := utils.LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	durations := map[string][2]time.Duration{
+		"Retention":                {config.Retention, 26 * time.Hour},
+		"DeletionNap":              {config.DeletionNap, 30 * time.Second},
+		"Quarantine.Duration":      {config.Quarantine.Duration, 36 * time.Hour},
+		"Backup.Retention":         {config.Backup.Retention, 14 * 24 * time.Hour},
+		"TerminationWatch.Timeout": {config.TerminationWatch.Timeout, 45 * time.Minute},
+		"Throttle.MinNap":          {config.Throttle.MinNap, 10 * time.Second},
+		"Throttle.MaxNap":          {config.Throttle.MaxNap, 5 * time.Minute},
+		"Teardown.StageTimeout":    {config.Teardown.StageTimeout, 90 * time.Second},
+		"Budget.CheckInterval":     {config.Budget.CheckInterval, 10 * time.Minute},
+	}
+	for key, duration := range durations {
+		if duration[0] != duration[1] {
+			t.Errorf("expected %s %s, got %s", key, duration[1], duration[0])
+		}
+	}
+}
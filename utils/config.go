@@ -1,171 +1,825 @@
 package utils
 
 import (
+	"NaNameUz3r/ReviewReaper/forge"
+	"bytes"
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
 )
 
 var (
-	defaultWeekDays      = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
-	NsPreserveAnnotation = "review-reaper-protected"
+	defaultWeekDays              = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	NsPreserveAnnotation         = "review-reaper-protected"
+	NsProtectedUntilAnnotation   = "review-reaper-protected-until"
+	NsProtectionReasonAnnotation = "review-reaper-protected-reason"
+	NsSourceRefAnnotation        = "review-reaper/source-ref"
+	NsExpiredByAnnotation        = "review-reaper/expired-by"
+	NsWakeAnnotation             = "review-reaper/wake"
+	NsQuarantineAnnotation       = "review-reaper/quarantined-at"
+	NsOwnerAnnotation            = "review-reaper/owner"
 
 	errMaintenanceDaysInvalid   = fmt.Errorf("Invalid weekdays in config DeletionWindow.WeekDays")
 	errMaintenanceWindowInvalid = fmt.Errorf(
-		"Timewindow invalid, NotBefore should be less than NotAfter",
+		"DeletionWindow invalid, NotBefore should be less than NotAfter",
+	)
+	errWorkingDaysInvalid  = fmt.Errorf("Invalid weekdays in config Hibernation.WorkingHours.WeekDays")
+	errWorkingHoursInvalid = fmt.Errorf(
+		"Hibernation.WorkingHours invalid, NotBefore should be less than NotAfter",
+	)
+	errWebhookSecretMissing     = fmt.Errorf("Webhook.Secret or Webhook.SecretFile is required when Webhook.Enabled is true")
+	errForgeTypeInvalid         = fmt.Errorf("Invalid Forge.Type, expected one of %v", forge.KnownTypes)
+	errForgeRepoMissing         = fmt.Errorf("Forge.Repository is required when Forge.Enabled is true")
+	errBackupBucketMissing      = fmt.Errorf("Backup.S3.Bucket is required when Backup.S3.Endpoint is set")
+	errOrphanCleanupModeInvalid = fmt.Errorf("Invalid OrphanCleanup.Mode, expected delete or report")
+	errThrottleBoundsInvalid    = fmt.Errorf("Throttle.MaxNap should not be less than Throttle.MinNap")
+	errThrottleQueryMissing     = fmt.Errorf("Throttle.MetricQuery.Query is required when Throttle.MetricQuery.URL is set")
+	errTeardownStagesInvalid    = fmt.Errorf("Invalid Teardown.Stages, expected a list of resource lists")
+	errQuotaStrategyInvalid     = fmt.Errorf("Invalid Quota.Strategy, expected one of %v", QuotaStrategies)
+	errQuotaGroupLabelMissing   = fmt.Errorf("Quota.GroupLabel is required when Quota.MaxPerGroup is set")
+	errBudgetLimitMissing       = fmt.Errorf("Budget.CPU or Budget.Memory is required when Budget.Enabled is true")
+	errBudgetIntervalInvalid    = fmt.Errorf("Invalid Budget.CheckInterval, expected a positive duration like 5m")
+	errBudgetStrategyInvalid    = fmt.Errorf("Invalid Budget.Strategy, expected one of %v", BudgetStrategies)
+	errAdminAPITokenMissing     = fmt.Errorf("AdminAPI.Token is required when AdminAPI.Enabled is true")
+	errAdmissionTLSMissing      = fmt.Errorf("Admission.CertFile and Admission.KeyFile are required when Admission.Enabled is true")
+	errAdmissionMaxTTLInvalid   = fmt.Errorf("Invalid Admission.MaxTTL, expected a non-negative duration like 14d")
+	errRetentionInvalid         = fmt.Errorf("Invalid Retention, expected a non-negative duration like 3d12h")
+	errDeletionNapInvalid       = fmt.Errorf("Invalid DeletionNap, expected a non-negative duration like 30s")
+	errRunOnceServerEnabled     = fmt.Errorf("is not available with RunOnce, a one-shot run does not serve requests")
+	errPropagationPolicyInvalid = fmt.Errorf(
+		"Invalid Teardown.PropagationPolicy, expected Background, Foreground or Orphan",
 	)
 )
 
+// QuotaStrategies are the ways to pick a namespace to evict when the quota is exceeded.
+var QuotaStrategies = []string{"oldest", "least-active", "shortest-ttl"}
+
+// BudgetStrategies are the ways to pick a namespace to evict when the resource budget is exceeded.
+var BudgetStrategies = []string{"largest", "oldest"}
+
+// TimeWindow is a daily HH:MM UTC interval on the allowed days of the week.
+type TimeWindow struct {
+	NotBefore string
+	NotAfter  string
+	WeekDays  []string
+}
+
 // TODO: Check if rest of the fields also can be validated. It's probably worth implementing a custom validation function and removing the validator.
 // TODO: Add ignored_namespaces parameter to preserve some namespaces, like ReviewReaper on its own, if it deployed by helm release and namespace named reviewreaper, fxmpl xDDD
 type Config struct {
-	NsNameDeletionRegexp string `validate:"required"`
-	DeletionRegexp       *regexp.Regexp
-	RetentionDays        int `validate:"gte=0"`
-	RetentionHours       int `validate:"gte=0"`
-	DeletionBatchSize    int `validate:"gte=0"`
-	DeletionNapSeconds   int `validate:"gte=0"`
-	IsUninstallReleases  bool
-	PostponeDeletion     bool
-	AnnotationKey        string
-	NsPreserveAnnotation string
-	DeletionWindow       struct {
-		NotBefore string
-		NotAfter  string
-		WeekDays  []string
+	NsNameDeletionRegexp         string `validate:"required"`
+	DeletionRegexp               *regexp.Regexp
+	Retention                    time.Duration
+	DeletionBatchSize            int `validate:"gte=0"`
+	DeletionNap                  time.Duration
+	IsUninstallReleases          bool
+	PostponeDeletion             bool
+	AnnotationKey                string
+	NsPreserveAnnotation         string
+	NsProtectedUntilAnnotation   string
+	NsProtectionReasonAnnotation string
+	NsSourceRefAnnotation        string
+	NsExpiredByAnnotation        string
+	NsWakeAnnotation             string
+	NsQuarantineAnnotation       string
+	DeletionWindow               TimeWindow
+	Webhook                      struct {
+		Enabled        bool
+		ListenAddress  string
+		Secret         string
+		SecretFile     string
+		BranchKey      string
+		PullRequestKey string
+	}
+	Forge struct {
+		Enabled    bool
+		Type       string
+		BaseURL    string
+		Repository string
+		Token      string
+	}
+	Hibernation struct {
+		Enabled      bool
+		WorkingHours TimeWindow
+	}
+	Quarantine struct {
+		Enabled  bool
+		Duration time.Duration
+	}
+	Backup struct {
+		Enabled        bool
+		IncludeSecrets bool
+		Retention      time.Duration
+		Directory      string
+		S3             struct {
+			Endpoint  string
+			Bucket    string
+			Prefix    string
+			Region    string
+			AccessKey string
+			SecretKey string
+		}
+	}
+	OrphanCleanup struct {
+		Enabled        bool
+		Mode           string
+		NamespaceLabel string
+	}
+	TerminationWatch struct {
+		Enabled          bool
+		Timeout          time.Duration
+		RemoveFinalizers bool
+	}
+	Throttle struct {
+		Adaptive    bool
+		MinNap      time.Duration
+		MaxNap      time.Duration
+		MetricQuery struct {
+			URL       string
+			Query     string
+			Threshold float64
+		}
+	}
+	Quota struct {
+		Enabled     bool
+		GroupLabel  string
+		MaxPerGroup int `validate:"gte=0"`
+		MaxTotal    int `validate:"gte=0"`
+		Strategy    string
+	}
+	Budget struct {
+		Enabled       bool
+		CPU           string
+		Memory        string
+		Strategy      string
+		CheckInterval time.Duration
+	}
+	AdminAPI struct {
+		Enabled       bool
+		ListenAddress string
+		Token         string
+		OwnerKey      string
+	}
+	Metrics struct {
+		Enabled       bool
+		ListenAddress string
+	}
+	Policies struct {
+		Enabled bool
+	}
+	Claims struct {
+		Enabled bool
+	}
+	Admission struct {
+		Enabled       bool
+		ListenAddress string
+		CertFile      string
+		KeyFile       string
+		// MaxTTL limits how far from now delete_after may be set, the governing retention limits it if zero.
+		MaxTTL               time.Duration
+		ProtectAllowedUsers  []string
+		ProtectAllowedGroups []string
+		ExemptUsers          []string
+	}
+	Teardown struct {
+		Stages            [][]string
+		StageTimeout      time.Duration
+		PropagationPolicy string
 	}
 
 	LogLevel string
 	DryRun   bool
+	RunOnce  bool
 }
 
 var validate = validator.New()
 
+const configReloadDelay = time.Second
+
 func LoadConfig() (config Config, err error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("/etc/app/")
-	viper.AddConfigPath("/app")
-	viper.AddConfigPath(".")
-	err = viper.ReadInConfig()
+	return LoadConfigFile("")
+}
+
+// WatchConfig calls onChange with the reloaded config, or with the error if the changed config is invalid.
+// The file is watched through its directory, so ConfigMap updates are noticed too. Events are coalesced
+// for configReloadDelay, so a file which is being written is not read half-way. The file is reloaded
+// into a fresh viper, as the global one is read by the viper watcher at the same time.
+func WatchConfig(onChange func(Config, error)) {
+	path := viper.ConfigFileUsed()
+	var reloadMutex, loadMutex sync.Mutex
+	var reloadTimer *time.Timer
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reloadMutex.Lock()
+		defer reloadMutex.Unlock()
+		if reloadTimer != nil {
+			reloadTimer.Stop()
+		}
+		reloadTimer = time.AfterFunc(configReloadDelay, func() {
+			// A timer might fire while the previous reload is still running.
+			loadMutex.Lock()
+			defer loadMutex.Unlock()
+			onChange(reloadConfigFile(path))
+		})
+	})
+	viper.WatchConfig()
+}
+
+// reloadConfigFile loads the config file into a fresh viper with the same flags bound.
+func reloadConfigFile(path string) (Config, error) {
+	v := viper.New()
+	for key, flag := range boundFlags {
+		if err := v.BindPFlag(key, flag); err != nil {
+			return Config{}, err
+		}
+	}
+	return loadConfigFile(v, path)
+}
+
+// ResolveConfigFile reads the given config file, or looks config.yaml up in the default paths
+// if the path is empty, and returns the path of the file read.
+func ResolveConfigFile(path string) (string, error) {
+	return resolveConfigFile(viper.GetViper(), path)
+}
+
+func resolveConfigFile(v *viper.Viper, path string) (string, error) {
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("config")
+		v.AddConfigPath("/etc/app/")
+		v.AddConfigPath("/app")
+		v.AddConfigPath(".")
+	}
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return "", err
+	}
+	return v.ConfigFileUsed(), nil
+}
+
+// LoadConfigFile loads the config from the given file, or looks config.yaml up
+// in the default paths if the path is empty.
+func LoadConfigFile(path string) (config Config, err error) {
+	return loadConfigFile(viper.GetViper(), path)
+}
+
+func loadConfigFile(v *viper.Viper, path string) (config Config, err error) {
+	if _, err := resolveConfigFile(v, path); err != nil {
+		return Config{}, err
+	}
+	v.SetEnvPrefix(ENV_PREFIX)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	settings, deprecations, isLegacy, err := readVersionedConfig(v.ConfigFileUsed())
 	if err != nil {
 		return Config{}, err
 	}
+	if isLegacy {
+		migrated, err := marshalVersionedConfig(settings)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := v.ReadConfig(bytes.NewReader(migrated)); err != nil {
+			return Config{}, err
+		}
+	}
+	envDeprecations, envVars, envErr := migrateLegacyEnv(v)
+	configDeprecations = append(deprecations, envDeprecations...)
+	legacyEnvVars = envVars
+
+	setDefaults(v)
+
+	// All errors are collected, so a broken config is fixed in one go.
+	errs := []error{checkUnknownKeys(settings), envErr}
 
-	viper.SetDefault("retention.days", 7)
-	viper.SetDefault("retention.hours", 0)
-	viper.SetDefault("DeletionBatchSize", 0)
-	viper.SetDefault("DeletionNapSeconds", 0)
-	viper.SetDefault("IsUninstallReleases", false)
-	viper.SetDefault("DeletionWindow.NotBefore", "00:00")
-	viper.SetDefault("DeletionWindow.NotAfter", "06:00")
-	viper.SetDefault("DeletionWindow.WeekDays", defaultWeekDays)
-	viper.SetDefault("AnnotationKey", "delete_after")
-	viper.SetDefault("PostoneNsDeletionByHelmDeploy", false)
-	viper.SetDefault("LogLevel", "INFO")
-	viper.SetDefault("DryRun", false)
 	config.NsPreserveAnnotation = NsPreserveAnnotation
+	config.NsProtectedUntilAnnotation = NsProtectedUntilAnnotation
+	config.NsProtectionReasonAnnotation = NsProtectionReasonAnnotation
+	config.NsSourceRefAnnotation = NsSourceRefAnnotation
+	config.NsExpiredByAnnotation = NsExpiredByAnnotation
+	config.NsWakeAnnotation = NsWakeAnnotation
+	config.NsQuarantineAnnotation = NsQuarantineAnnotation
 
-	config.NsNameDeletionRegexp = viper.GetString("NsNameDeletionRegexp")
-	config.RetentionDays = viper.GetInt("Retention.Days")
-	config.RetentionHours = viper.GetInt("Retention.Hours")
+	config.NsNameDeletionRegexp = v.GetString("NsNameDeletionRegexp")
+	if config.Retention, err = ParseDuration(v.GetString("Retention")); err != nil {
+		errs = append(errs, fmt.Errorf("%w, got %q", errRetentionInvalid, v.GetString("Retention")))
+	}
 
-	config.DeletionBatchSize = viper.GetInt("DeletionBatchSize")
-	config.DeletionNapSeconds = viper.GetInt("DeletionNapSeconds")
+	config.DeletionBatchSize = getInt(v, "DeletionBatchSize", &errs)
+	if config.DeletionNap, err = ParseDuration(v.GetString("DeletionNap")); err != nil {
+		errs = append(errs, fmt.Errorf("%w, got %q", errDeletionNapInvalid, v.GetString("DeletionNap")))
+	}
 
-	config.IsUninstallReleases = viper.GetBool("IsUninstallReleases")
-	config.AnnotationKey = viper.GetString("AnnotationKey")
+	config.IsUninstallReleases = getBool(v, "UninstallReleases", &errs)
+	config.AnnotationKey = v.GetString("AnnotationKey")
 
-	config.DeletionWindow.NotBefore = viper.GetString("DeletionWindow.NotBefore")
-	config.DeletionWindow.NotAfter = viper.GetString("DeletionWindow.NotAfter")
-	config.DeletionWindow.WeekDays = viper.GetStringSlice("DeletionWindow.WeekDays")
-	config.PostponeDeletion = viper.GetBool("PostoneNsDeletionByHelmDeploy")
+	config.DeletionWindow.NotBefore = v.GetString("DeletionWindow.NotBefore")
+	config.DeletionWindow.NotAfter = v.GetString("DeletionWindow.NotAfter")
+	config.DeletionWindow.WeekDays = getStringSlice(v, "DeletionWindow.WeekDays")
+	config.PostponeDeletion = getBool(v, "PostponeDeletionByHelmDeploy", &errs)
 
-	config.LogLevel = viper.GetString("LogLevel")
-	config.DryRun = viper.GetBool("DryRun")
+	config.Webhook.Enabled = getBool(v, "Webhook.Enabled", &errs)
+	config.Webhook.ListenAddress = v.GetString("Webhook.ListenAddress")
+	config.Webhook.Secret = v.GetString("Webhook.Secret")
+	config.Webhook.SecretFile = v.GetString("Webhook.SecretFile")
+	if config.Webhook.SecretFile != "" {
+		// The file is a mounted Secret key, it wins over a secret leaked into the ConfigMap.
+		if secret, err := os.ReadFile(config.Webhook.SecretFile); err != nil {
+			errs = append(errs, fmt.Errorf("Unable to read Webhook.SecretFile: %w", err))
+		} else {
+			config.Webhook.Secret = strings.TrimSpace(string(secret))
+		}
+	}
+	config.Webhook.BranchKey = v.GetString("Webhook.BranchKey")
+	config.Webhook.PullRequestKey = v.GetString("Webhook.PullRequestKey")
 
-	sortWeekDays(&config)
+	config.Forge.Enabled = getBool(v, "Forge.Enabled", &errs)
+	config.Forge.Type = strings.ToLower(v.GetString("Forge.Type"))
+	config.Forge.BaseURL = v.GetString("Forge.BaseURL")
+	config.Forge.Repository = v.GetString("Forge.Repository")
+	config.Forge.Token = v.GetString("Forge.Token")
 
-	// safeChecks
-	err = validate.Struct(config)
+	config.Hibernation.Enabled = getBool(v, "Hibernation.Enabled", &errs)
+	config.Hibernation.WorkingHours.NotBefore = v.GetString("Hibernation.WorkingHours.NotBefore")
+	config.Hibernation.WorkingHours.NotAfter = v.GetString("Hibernation.WorkingHours.NotAfter")
+	config.Hibernation.WorkingHours.WeekDays = getStringSlice(v, "Hibernation.WorkingHours.WeekDays")
+
+	config.Quarantine.Enabled = getBool(v, "Quarantine.Enabled", &errs)
+	config.Quarantine.Duration = getDuration(v, "Quarantine.Duration", &errs)
+
+	config.Backup.Enabled = getBool(v, "Backup.Enabled", &errs)
+	config.Backup.IncludeSecrets = getBool(v, "Backup.IncludeSecrets", &errs)
+	config.Backup.Retention = getDuration(v, "Backup.Retention", &errs)
+	config.Backup.Directory = v.GetString("Backup.Directory")
+	config.Backup.S3.Endpoint = v.GetString("Backup.S3.Endpoint")
+	config.Backup.S3.Bucket = v.GetString("Backup.S3.Bucket")
+	config.Backup.S3.Prefix = v.GetString("Backup.S3.Prefix")
+	config.Backup.S3.Region = v.GetString("Backup.S3.Region")
+	config.Backup.S3.AccessKey = v.GetString("Backup.S3.AccessKey")
+	config.Backup.S3.SecretKey = v.GetString("Backup.S3.SecretKey")
+
+	config.OrphanCleanup.Enabled = getBool(v, "OrphanCleanup.Enabled", &errs)
+	config.OrphanCleanup.Mode = strings.ToLower(v.GetString("OrphanCleanup.Mode"))
+	config.OrphanCleanup.NamespaceLabel = v.GetString("OrphanCleanup.NamespaceLabel")
+
+	config.TerminationWatch.Enabled = getBool(v, "TerminationWatch.Enabled", &errs)
+	config.TerminationWatch.Timeout = getDuration(v, "TerminationWatch.Timeout", &errs)
+	config.TerminationWatch.RemoveFinalizers = getBool(v, "TerminationWatch.RemoveFinalizers", &errs)
+
+	config.Throttle.Adaptive = getBool(v, "Throttle.Adaptive", &errs)
+	config.Throttle.MinNap = getDuration(v, "Throttle.MinNap", &errs)
+	config.Throttle.MaxNap = getDuration(v, "Throttle.MaxNap", &errs)
+	config.Throttle.MetricQuery.URL = strings.TrimSuffix(v.GetString("Throttle.MetricQuery.URL"), "/")
+	config.Throttle.MetricQuery.Query = v.GetString("Throttle.MetricQuery.Query")
+	config.Throttle.MetricQuery.Threshold = getFloat64(v, "Throttle.MetricQuery.Threshold", &errs)
+
+	// A list of lists can not be split from an env var, so it is passed as YAML or JSON.
+	if stages, ok := v.Get("Teardown.Stages").(string); ok {
+		err = yaml.Unmarshal([]byte(stages), &config.Teardown.Stages)
+	} else {
+		err = v.UnmarshalKey("Teardown.Stages", &config.Teardown.Stages)
+	}
 	if err != nil {
-		return Config{}, err
+		errs = append(errs, errTeardownStagesInvalid)
 	}
+	config.Teardown.StageTimeout = getDuration(v, "Teardown.StageTimeout", &errs)
+	config.Teardown.PropagationPolicy = v.GetString("Teardown.PropagationPolicy")
+
+	config.Quota.Enabled = getBool(v, "Quota.Enabled", &errs)
+	config.Quota.GroupLabel = v.GetString("Quota.GroupLabel")
+	config.Quota.MaxPerGroup = getInt(v, "Quota.MaxPerGroup", &errs)
+	config.Quota.MaxTotal = getInt(v, "Quota.MaxTotal", &errs)
+	config.Quota.Strategy = strings.ToLower(v.GetString("Quota.Strategy"))
+
+	config.Budget.Enabled = getBool(v, "Budget.Enabled", &errs)
+	config.Budget.CPU = v.GetString("Budget.CPU")
+	config.Budget.Memory = v.GetString("Budget.Memory")
+	config.Budget.Strategy = strings.ToLower(v.GetString("Budget.Strategy"))
+	config.Budget.CheckInterval = getDuration(v, "Budget.CheckInterval", &errs)
+
+	config.AdminAPI.Enabled = getBool(v, "AdminAPI.Enabled", &errs)
+	config.AdminAPI.ListenAddress = v.GetString("AdminAPI.ListenAddress")
+	config.AdminAPI.Token = v.GetString("AdminAPI.Token")
+	config.AdminAPI.OwnerKey = v.GetString("AdminAPI.OwnerKey")
+
+	config.Metrics.Enabled = getBool(v, "Metrics.Enabled", &errs)
+	config.Metrics.ListenAddress = v.GetString("Metrics.ListenAddress")
+
+	config.Policies.Enabled = getBool(v, "Policies.Enabled", &errs)
+	config.Claims.Enabled = getBool(v, "Claims.Enabled", &errs)
+
+	config.Admission.Enabled = getBool(v, "Admission.Enabled", &errs)
+	config.Admission.ListenAddress = v.GetString("Admission.ListenAddress")
+	config.Admission.CertFile = v.GetString("Admission.CertFile")
+	config.Admission.KeyFile = v.GetString("Admission.KeyFile")
+	if maxTTL := v.GetString("Admission.MaxTTL"); maxTTL != "" {
+		if config.Admission.MaxTTL, err = ParseDuration(maxTTL); err != nil {
+			errs = append(errs, fmt.Errorf("%w, got %q", errAdmissionMaxTTLInvalid, maxTTL))
+		}
+	}
+	config.Admission.ProtectAllowedUsers = getStringSlice(v, "Admission.ProtectAllowedUsers")
+	config.Admission.ProtectAllowedGroups = getStringSlice(v, "Admission.ProtectAllowedGroups")
+	config.Admission.ExemptUsers = getStringSlice(v, "Admission.ExemptUsers")
+
+	config.LogLevel = v.GetString("LogLevel")
+	config.DryRun = getBool(v, "DryRun", &errs)
+	config.RunOnce = getBool(v, "RunOnce", &errs)
+
+	// safeChecks
+	errs = append(errs, structErrors(validate.Struct(config))...)
 
 	config.DeletionRegexp, err = regexp.Compile(config.NsNameDeletionRegexp)
 	if err != nil {
-		return Config{}, errors.New("Unable to compile regexp")
+		errs = append(errs, fmt.Errorf("Unable to compile NsNameDeletionRegexp: %w", err))
 	}
 
-	return config, validateConfig(config)
+	errs = append(errs, validateConfig(config))
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+
+	sortWeekDays(&config)
+	return config, nil
+}
+
+// structErrors describes the validator tag failures with the config key paths.
+func structErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []error{err}
+	}
+
+	errs := make([]error, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		field := strings.TrimPrefix(fieldErr.Namespace(), "Config.")
+		switch fieldErr.Tag() {
+		case "required":
+			errs = append(errs, fmt.Errorf("%s is required", field))
+		case "gte":
+			errs = append(errs, fmt.Errorf("%s should be at least %s, got %v", field, fieldErr.Param(), fieldErr.Value()))
+		default:
+			errs = append(errs, fmt.Errorf("%s is invalid: %s", field, fieldErr.Error()))
+		}
+	}
+	return errs
+}
+
+// defaultSetter is viper, or a recorder of the known keys for the strict decoding and the JSON Schema.
+type defaultSetter interface {
+	SetDefault(key string, value interface{})
+}
+
+// setDefaults sets defaults of all config keys, a key without a default is unknown.
+func setDefaults(v defaultSetter) {
+	v.SetDefault("NsNameDeletionRegexp", "")
+	v.SetDefault("Retention", "7d")
+	v.SetDefault("DeletionBatchSize", 0)
+	v.SetDefault("DeletionNap", "0s")
+	v.SetDefault("UninstallReleases", false)
+	v.SetDefault("DeletionWindow.NotBefore", "00:00")
+	v.SetDefault("DeletionWindow.NotAfter", "06:00")
+	v.SetDefault("DeletionWindow.WeekDays", defaultWeekDays)
+	v.SetDefault("AnnotationKey", "delete_after")
+	v.SetDefault("PostponeDeletionByHelmDeploy", false)
+	v.SetDefault("Webhook.Enabled", false)
+	v.SetDefault("Webhook.ListenAddress", ":8080")
+	v.SetDefault("Webhook.Secret", "")
+	v.SetDefault("Webhook.SecretFile", "")
+	v.SetDefault("Webhook.BranchKey", NsSourceRefAnnotation)
+	v.SetDefault("Webhook.PullRequestKey", "review-reaper/pull-request")
+	v.SetDefault("Forge.Enabled", false)
+	v.SetDefault("Forge.Type", "github")
+	v.SetDefault("Forge.BaseURL", "")
+	v.SetDefault("Forge.Repository", "")
+	v.SetDefault("Forge.Token", "")
+	v.SetDefault("Hibernation.Enabled", false)
+	v.SetDefault("Hibernation.WorkingHours.NotBefore", "08:00")
+	v.SetDefault("Hibernation.WorkingHours.NotAfter", "20:00")
+	v.SetDefault("Hibernation.WorkingHours.WeekDays", defaultWeekDays[:5])
+	v.SetDefault("Quarantine.Enabled", false)
+	v.SetDefault("Quarantine.Duration", "24h")
+	v.SetDefault("Backup.Enabled", false)
+	v.SetDefault("Backup.IncludeSecrets", false)
+	v.SetDefault("Backup.Retention", "30d")
+	v.SetDefault("Backup.Directory", "/backup")
+	v.SetDefault("Backup.S3.Endpoint", "")
+	v.SetDefault("Backup.S3.Bucket", "")
+	v.SetDefault("Backup.S3.Prefix", "")
+	v.SetDefault("Backup.S3.Region", "us-east-1")
+	v.SetDefault("Backup.S3.AccessKey", "")
+	v.SetDefault("Backup.S3.SecretKey", "")
+	v.SetDefault("OrphanCleanup.Enabled", false)
+	v.SetDefault("OrphanCleanup.Mode", "delete")
+	v.SetDefault("OrphanCleanup.NamespaceLabel", "review-reaper/namespace")
+	v.SetDefault("TerminationWatch.Enabled", true)
+	v.SetDefault("TerminationWatch.Timeout", "30m")
+	v.SetDefault("TerminationWatch.RemoveFinalizers", false)
+	v.SetDefault("Teardown.Stages", [][]string{})
+	v.SetDefault("Teardown.StageTimeout", "5m")
+	v.SetDefault("Teardown.PropagationPolicy", "")
+	v.SetDefault("Throttle.Adaptive", false)
+	v.SetDefault("Throttle.MinNap", "0s")
+	v.SetDefault("Throttle.MaxNap", "5m")
+	v.SetDefault("Throttle.MetricQuery.URL", "")
+	v.SetDefault("Throttle.MetricQuery.Query", "")
+	v.SetDefault("Throttle.MetricQuery.Threshold", 0.0)
+	v.SetDefault("Quota.Enabled", false)
+	v.SetDefault("Quota.GroupLabel", "")
+	v.SetDefault("Quota.MaxPerGroup", 0)
+	v.SetDefault("Quota.MaxTotal", 0)
+	v.SetDefault("Quota.Strategy", "oldest")
+	v.SetDefault("Budget.Enabled", false)
+	v.SetDefault("Budget.CPU", "")
+	v.SetDefault("Budget.Memory", "")
+	v.SetDefault("Budget.Strategy", "largest")
+	v.SetDefault("Budget.CheckInterval", "5m")
+	v.SetDefault("AdminAPI.Enabled", false)
+	v.SetDefault("AdminAPI.ListenAddress", ":8081")
+	v.SetDefault("AdminAPI.Token", "")
+	v.SetDefault("AdminAPI.OwnerKey", NsOwnerAnnotation)
+	v.SetDefault("Metrics.Enabled", false)
+	v.SetDefault("Metrics.ListenAddress", ":9102")
+	v.SetDefault("Policies.Enabled", false)
+	v.SetDefault("Claims.Enabled", false)
+	v.SetDefault("Admission.Enabled", false)
+	v.SetDefault("Admission.ListenAddress", ":8443")
+	v.SetDefault("Admission.CertFile", "/etc/review-reaper/tls/tls.crt")
+	v.SetDefault("Admission.KeyFile", "/etc/review-reaper/tls/tls.key")
+	v.SetDefault("Admission.MaxTTL", "")
+	v.SetDefault("Admission.ProtectAllowedUsers", []string{})
+	v.SetDefault("Admission.ProtectAllowedGroups", []string{})
+	v.SetDefault("Admission.ExemptUsers", []string{})
+	v.SetDefault("LogLevel", "INFO")
+	v.SetDefault("DryRun", false)
+	v.SetDefault("RunOnce", false)
 }
 
 func validateConfig(c Config) (err error) {
 	validationFuncs := []func(Config) error{
+		validateDurations,
 		validateWeekDays,
 		validateTimeWindow,
+		validateWebhook,
+		validateForge,
+		validateBackup,
+		validateOrphanCleanup,
+		validateThrottle,
+		validateTeardown,
+		validateQuota,
+		validateBudget,
+		validateAdminAPI,
+		validateAdmission,
+		validateRunOnce,
 	}
 
+	errs := make([]error, 0)
 	for _, f := range validationFuncs {
-		if err := f(c); err != nil {
-			return err
-		}
+		errs = append(errs, f(c))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func validateWeekDays(c Config) error {
-	validWeekdays := map[string]bool{
-		"Mon": true,
-		"Tue": true,
-		"Wed": true,
-		"Thu": true,
-		"Fri": true,
-		"Sat": true,
-		"Sun": true,
-	}
-
-	for _, day := range c.DeletionWindow.WeekDays {
-		if len(day) != 3 || !validWeekdays[day] {
-			return errMaintenanceDaysInvalid
+	errs := invalidWeekDays(c.DeletionWindow.WeekDays, errMaintenanceDaysInvalid)
+	if c.Hibernation.Enabled {
+		errs = append(errs, invalidWeekDays(c.Hibernation.WorkingHours.WeekDays, errWorkingDaysInvalid)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func invalidWeekDays(weekDays []string, errInvalid error) []error {
+	errs := make([]error, 0)
+	for _, day := range weekDays {
+		if !IsContains(defaultWeekDays, day) {
+			errs = append(errs, fmt.Errorf("%w: %q, expected one of %s", errInvalid, day, strings.Join(defaultWeekDays, ", ")))
 		}
 	}
 
-	return nil
+	return errs
 }
 
 func validateTimeWindow(c Config) error {
-	HH_MM := "15:04"
-	notBefore, err := time.Parse(HH_MM, c.DeletionWindow.NotBefore)
-	if err != nil {
+	if err := checkTimeWindow("DeletionWindow", c.DeletionWindow, errMaintenanceWindowInvalid); err != nil {
 		return err
 	}
-	notAfter, err := time.Parse(HH_MM, c.DeletionWindow.NotAfter)
-	if err != nil {
+	if c.Hibernation.Enabled {
+		return checkTimeWindow("Hibernation.WorkingHours", c.Hibernation.WorkingHours, errWorkingHoursInvalid)
+	}
+
+	return nil
+}
+
+// NewTimeWindow validates a window which does not come from the config file, like the one of a ReaperPolicy.
+// Empty weekDays mean every day.
+func NewTimeWindow(name string, notBefore string, notAfter string, weekDays []string) (TimeWindow, error) {
+	if len(weekDays) == 0 {
+		weekDays = defaultWeekDays
+	}
+	w := TimeWindow{NotBefore: notBefore, NotAfter: notAfter, WeekDays: weekDays}
+
+	errs := invalidWeekDays(w.WeekDays, fmt.Errorf("Invalid weekdays in %s.WeekDays", name))
+	errs = append(errs, checkTimeWindow(name, w, fmt.Errorf("%s invalid, NotBefore should be less than NotAfter", name)))
+	w.WeekDays = sortedWeekDays(w.WeekDays)
+	return w, errors.Join(errs...)
+}
+
+func checkTimeWindow(name string, w TimeWindow, errInvalid error) error {
+	HH_MM := "15:04"
+	notBefore, errNotBefore := time.Parse(HH_MM, w.NotBefore)
+	if errNotBefore != nil {
+		errNotBefore = fmt.Errorf("%s.NotBefore should be HH:MM, got %q", name, w.NotBefore)
+	}
+	notAfter, errNotAfter := time.Parse(HH_MM, w.NotAfter)
+	if errNotAfter != nil {
+		errNotAfter = fmt.Errorf("%s.NotAfter should be HH:MM, got %q", name, w.NotAfter)
+	}
+	if err := errors.Join(errNotBefore, errNotAfter); err != nil {
 		return err
 	}
 
 	if notBefore.Equal(notAfter) {
-		return errMaintenanceWindowInvalid
+		return errInvalid
 	}
 	if notAfter.Before(notBefore) {
-		return errMaintenanceWindowInvalid
+		return errInvalid
 	}
 
 	return nil
 }
 
+func validateWebhook(c Config) error {
+	if c.Webhook.Enabled && c.Webhook.Secret == "" {
+		return errWebhookSecretMissing
+	}
+	return nil
+}
+
+func validateForge(c Config) error {
+	if !c.Forge.Enabled {
+		return nil
+	}
+	errs := make([]error, 0)
+	if !IsContains(forge.KnownTypes, c.Forge.Type) {
+		errs = append(errs, fmt.Errorf("%w, got %q", errForgeTypeInvalid, c.Forge.Type))
+	}
+	if c.Forge.Repository == "" {
+		errs = append(errs, errForgeRepoMissing)
+	}
+	return errors.Join(errs...)
+}
+
+func validateBackup(c Config) error {
+	if c.Backup.Enabled && c.Backup.S3.Endpoint != "" && c.Backup.S3.Bucket == "" {
+		return errBackupBucketMissing
+	}
+	return nil
+}
+
+func validateOrphanCleanup(c Config) error {
+	validModes := []string{"delete", "report"}
+	if c.OrphanCleanup.Enabled && !IsContains(validModes, c.OrphanCleanup.Mode) {
+		return fmt.Errorf("%w, got %q", errOrphanCleanupModeInvalid, c.OrphanCleanup.Mode)
+	}
+	return nil
+}
+
+func validateThrottle(c Config) error {
+	errs := make([]error, 0)
+	if c.Throttle.Adaptive && c.Throttle.MaxNap < c.Throttle.MinNap {
+		errs = append(errs, errThrottleBoundsInvalid)
+	}
+	if c.Throttle.MetricQuery.URL != "" && c.Throttle.MetricQuery.Query == "" {
+		errs = append(errs, errThrottleQueryMissing)
+	}
+	return errors.Join(errs...)
+}
+
+func validateTeardown(c Config) error {
+	validPolicies := []string{"", "Background", "Foreground", "Orphan"}
+	if !IsContains(validPolicies, c.Teardown.PropagationPolicy) {
+		return fmt.Errorf("%w, got %q", errPropagationPolicyInvalid, c.Teardown.PropagationPolicy)
+	}
+	return nil
+}
+
+func validateQuota(c Config) error {
+	if !c.Quota.Enabled {
+		return nil
+	}
+	errs := make([]error, 0)
+	if !IsContains(QuotaStrategies, c.Quota.Strategy) {
+		errs = append(errs, fmt.Errorf("%w, got %q", errQuotaStrategyInvalid, c.Quota.Strategy))
+	}
+	if c.Quota.MaxPerGroup > 0 && c.Quota.GroupLabel == "" {
+		errs = append(errs, errQuotaGroupLabelMissing)
+	}
+	return errors.Join(errs...)
+}
+
+func validateBudget(c Config) error {
+	if !c.Budget.Enabled {
+		return nil
+	}
+	errs := make([]error, 0)
+	if c.Budget.CPU == "" && c.Budget.Memory == "" {
+		errs = append(errs, errBudgetLimitMissing)
+	}
+	if c.Budget.CheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("%w, got %s", errBudgetIntervalInvalid, c.Budget.CheckInterval))
+	}
+	quantities := map[string]string{"Budget.CPU": c.Budget.CPU, "Budget.Memory": c.Budget.Memory}
+	for _, key := range []string{"Budget.CPU", "Budget.Memory"} {
+		if quantities[key] == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(quantities[key]); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid %s quantity %q: %w", key, quantities[key], err))
+		}
+	}
+	if !IsContains(BudgetStrategies, c.Budget.Strategy) {
+		errs = append(errs, fmt.Errorf("%w, got %q", errBudgetStrategyInvalid, c.Budget.Strategy))
+	}
+	return errors.Join(errs...)
+}
+
+func validateAdminAPI(c Config) error {
+	if c.AdminAPI.Enabled && c.AdminAPI.Token == "" {
+		return errAdminAPITokenMissing
+	}
+	return nil
+}
+
+func validateAdmission(c Config) error {
+	errs := make([]error, 0)
+	if c.Admission.Enabled && (c.Admission.CertFile == "" || c.Admission.KeyFile == "") {
+		errs = append(errs, errAdmissionTLSMissing)
+	}
+	if c.Admission.MaxTTL < 0 {
+		errs = append(errs, fmt.Errorf("%w, got %s", errAdmissionMaxTTLInvalid, c.Admission.MaxTTL))
+	}
+	return errors.Join(errs...)
+}
+
+func validateRunOnce(c Config) error {
+	if !c.RunOnce {
+		return nil
+	}
+	servers := []struct {
+		name      string
+		isEnabled bool
+	}{
+		{"Webhook", c.Webhook.Enabled},
+		{"AdminAPI", c.AdminAPI.Enabled},
+		{"Admission", c.Admission.Enabled},
+	}
+	errs := make([]error, 0)
+	for _, server := range servers {
+		if server.isEnabled {
+			errs = append(errs, fmt.Errorf("%s.Enabled %w", server.name, errRunOnceServerEnabled))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateDurations(c Config) error {
+	errs := make([]error, 0)
+	if c.Retention < 0 {
+		errs = append(errs, fmt.Errorf("%w, got %s", errRetentionInvalid, c.Retention))
+	}
+	if c.DeletionNap < 0 {
+		errs = append(errs, fmt.Errorf("%w, got %s", errDeletionNapInvalid, c.DeletionNap))
+	}
+
+	return errors.Join(errs...)
+}
+
 func sortWeekDays(c *Config) {
-	sortedWeekDays := []string{}
+	c.DeletionWindow.WeekDays = sortedWeekDays(c.DeletionWindow.WeekDays)
+	c.Hibernation.WorkingHours.WeekDays = sortedWeekDays(c.Hibernation.WorkingHours.WeekDays)
+}
+
+func sortedWeekDays(weekDays []string) []string {
+	sorted := []string{}
 	for _, day := range defaultWeekDays {
-		if IsContains(c.DeletionWindow.WeekDays, day) {
-			sortedWeekDays = append(sortedWeekDays, day)
+		if IsContains(weekDays, day) {
+			sorted = append(sorted, day)
 		}
 	}
-	c.DeletionWindow.WeekDays = sortedWeekDays
+	return sorted
 }
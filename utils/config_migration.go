@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+)
+
+// CONFIG_API_VERSION is the current config file format. Files without apiVersion have the legacy
+// layout and are migrated in memory.
+const CONFIG_API_VERSION = "reviewreaper/v1"
+
+// renamedKeys are legacy keys, in lower case, and their reviewreaper/v1 names.
+var renamedKeys = map[string]string{
+	"postonensdeletionbyhelmdeploy": "PostponeDeletionByHelmDeploy",
+	"isuninstallreleases":           "UninstallReleases",
+}
+
+// unitKeys are legacy keys holding a bare number, in lower case, and their reviewreaper/v1 durations.
+var unitKeys = map[string]struct {
+	name string
+	unit time.Duration
+}{
+	"deletionnapseconds":              {"DeletionNap", time.Second},
+	"quarantine.hours":                {"Quarantine.Duration", time.Hour},
+	"backup.retentiondays":            {"Backup.Retention", 24 * time.Hour},
+	"terminationwatch.timeoutminutes": {"TerminationWatch.Timeout", time.Minute},
+	"throttle.minnapseconds":          {"Throttle.MinNap", time.Second},
+	"throttle.maxnapseconds":          {"Throttle.MaxNap", time.Second},
+	"teardown.stagetimeoutseconds":    {"Teardown.StageTimeout", time.Second},
+	"budget.checkintervalminutes":     {"Budget.CheckInterval", time.Minute},
+}
+
+// legacyRetentionKeys are legacy keys, in lower case, which make up the Retention duration together.
+var legacyRetentionKeys = []string{"retention.days", "retention.hours"}
+
+// configDeprecations are the warnings about legacy keys of the loaded config file and env vars.
+// legacyEnvVars are the legacy env vars, by lower case key, which set the keys of the loaded config.
+var (
+	configDeprecations []string
+	legacyEnvVars      map[string]string
+)
+
+// readVersionedConfig reads the config file, migrating it from the legacy layout if it has no apiVersion.
+// The warnings name every migrated legacy key.
+func readVersionedConfig(path string) (settings map[string]interface{}, warnings []string, isLegacy bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	settings = map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, nil, false, err
+	}
+
+	switch apiVersion := settings["apiVersion"]; apiVersion {
+	case CONFIG_API_VERSION:
+		return settings, nil, false, nil
+	case nil:
+		settings, warnings, err = migrateLegacyConfig(settings)
+		return settings, warnings, true, err
+	default:
+		return nil, nil, false, fmt.Errorf("Unsupported config apiVersion %v, expected %s", apiVersion, CONFIG_API_VERSION)
+	}
+}
+
+// migrateLegacyConfig renames legacy keys, converts Retention.Days, Retention.Hours and the unitKeys
+// to durations, and puts known keys in the documented case.
+func migrateLegacyConfig(legacy map[string]interface{}) (map[string]interface{}, []string, error) {
+	warnings := []string{
+		fmt.Sprintf("Config key apiVersion is missing, set apiVersion: %s or run review-reaper migrate-config", CONFIG_API_VERSION),
+	}
+	settings := map[string]interface{}{}
+	for key, value := range legacy {
+		lowerKey := strings.ToLower(key)
+		switch {
+		case renamedKeys[lowerKey] != "":
+			settings[renamedKeys[lowerKey]] = value
+			warnings = append(warnings, deprecationWarning("Config key", key, renamedKeys[lowerKey]+": ", value))
+		case lowerKey == "retention":
+			retention, err := migrateRetention(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			settings["Retention"] = retention
+			if legacyRetention, ok := value.(map[string]interface{}); ok {
+				for legacyKey := range legacyRetention {
+					warnings = append(warnings, deprecationWarning("Config key", key+"."+legacyKey, "Retention: ", retention))
+				}
+			}
+		default:
+			settings[key] = value
+		}
+	}
+
+	if err := migrateUnitKeys(settings, "", &warnings); err != nil {
+		return nil, nil, err
+	}
+	settings = canonicalKeys(settings, "", canonicalNames())
+	settings["apiVersion"] = CONFIG_API_VERSION
+	sort.Strings(warnings[1:])
+	return settings, warnings, nil
+}
+
+func deprecationWarning(kind string, legacy string, replacement string, value interface{}) string {
+	return fmt.Sprintf("%s %s is deprecated, use %s%v", kind, legacy, replacement, value)
+}
+
+// migrateLegacyEnv sets the keys from the env vars of the legacy keys during the deprecation period,
+// unless the env vars of the keys are set, and returns a warning for every legacy env var.
+func migrateLegacyEnv(v *viper.Viper) (warnings []string, envVars map[string]string, err error) {
+	warnings = make([]string, 0)
+	envVars = map[string]string{}
+	errs := make([]error, 0)
+	migrate := func(legacyKey string, key string, convert func(string) (interface{}, error)) {
+		legacyEnvVar := EnvVar(legacyKey)
+		value := os.Getenv(legacyEnvVar)
+		if value == "" {
+			return
+		}
+		if os.Getenv(EnvVar(key)) != "" {
+			warnings = append(warnings, fmt.Sprintf("Env var %s is deprecated and ignored, %s is set", legacyEnvVar, EnvVar(key)))
+			return
+		}
+		converted, err := convert(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid %s %q: %w", legacyEnvVar, value, err))
+			return
+		}
+		v.Set(key, converted)
+		envVars[strings.ToLower(key)] = legacyEnvVar
+		warnings = append(warnings, deprecationWarning("Env var", legacyEnvVar, EnvVar(key)+"=", converted))
+	}
+
+	for legacyKey, key := range renamedKeys {
+		migrate(legacyKey, key, func(value string) (interface{}, error) {
+			return value, nil
+		})
+	}
+	for legacyKey, renamed := range unitKeys {
+		unit := renamed.unit
+		migrate(legacyKey, renamed.name, func(value string) (interface{}, error) {
+			number, err := cast.ToIntE(value)
+			return FormatDuration(time.Duration(number) * unit), err
+		})
+	}
+	// Both legacy env vars make up the retention, each of them is reported.
+	for _, legacyKey := range legacyRetentionKeys {
+		migrate(legacyKey, "Retention", func(string) (interface{}, error) {
+			legacyRetention := map[string]interface{}{}
+			for _, retentionKey := range legacyRetentionKeys {
+				if value := os.Getenv(EnvVar(retentionKey)); value != "" {
+					legacyRetention[strings.TrimPrefix(retentionKey, "retention.")] = value
+				}
+			}
+			return migrateRetention(legacyRetention)
+		})
+	}
+
+	sort.Strings(warnings)
+	return warnings, envVars, errors.Join(errs...)
+}
+
+// migrateRetention converts the legacy Days and Hours map, which default to 7 and 0, to a duration.
+func migrateRetention(value interface{}) (interface{}, error) {
+	legacy, ok := value.(map[string]interface{})
+	if !ok {
+		return value, nil
+	}
+
+	days, hours := 7, 0
+	for key, value := range legacy {
+		var err error
+		switch strings.ToLower(key) {
+		case "days":
+			days, err = cast.ToIntE(value)
+		case "hours":
+			hours, err = cast.ToIntE(value)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Retention.%s %v: %w", key, value, err)
+		}
+	}
+	return FormatDuration(time.Duration(days)*24*time.Hour + time.Duration(hours)*time.Hour), nil
+}
+
+// migrateUnitKeys replaces the unitKeys in the sections with durations under their new names.
+func migrateUnitKeys(settings map[string]interface{}, prefix string, warnings *[]string) error {
+	for key, value := range settings {
+		path := strings.ToLower(prefix + key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			if err := migrateUnitKeys(nested, prefix+key+".", warnings); err != nil {
+				return err
+			}
+			continue
+		}
+		renamed, ok := unitKeys[path]
+		if !ok {
+			continue
+		}
+		number, err := cast.ToIntE(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s%s %v: %w", prefix, key, value, err)
+		}
+		duration := FormatDuration(time.Duration(number) * renamed.unit)
+		delete(settings, key)
+		settings[renamed.name[strings.LastIndex(renamed.name, ".")+1:]] = duration
+		*warnings = append(*warnings, deprecationWarning("Config key", prefix+key, renamed.name+": ", duration))
+	}
+	return nil
+}
+
+// canonicalNames maps lower case paths of config keys and sections to their documented names.
+func canonicalNames() map[string]string {
+	names := map[string]string{}
+	for _, key := range knownKeys() {
+		path := strings.Split(key.name, ".")
+		for i := range path {
+			names[strings.ToLower(strings.Join(path[:i+1], "."))] = path[i]
+		}
+	}
+	return names
+}
+
+func canonicalKeys(settings map[string]interface{}, prefix string, names map[string]string) map[string]interface{} {
+	canonical := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		path := strings.ToLower(prefix + key)
+		if name, ok := names[path]; ok {
+			key = name
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = canonicalKeys(nested, path+".", names)
+		}
+		canonical[key] = value
+	}
+	return canonical
+}
+
+// MigrateConfigFile returns the config file in the reviewreaper/v1 format and whether it had the legacy layout.
+// Comments are not preserved.
+func MigrateConfigFile(path string) ([]byte, bool, error) {
+	settings, _, isLegacy, err := readVersionedConfig(path)
+	if err != nil || !isLegacy {
+		return nil, isLegacy, err
+	}
+	data, err := marshalVersionedConfig(settings)
+	return data, true, err
+}
+
+// marshalVersionedConfig puts apiVersion on top, as YAML keys are sorted otherwise.
+func marshalVersionedConfig(settings map[string]interface{}) ([]byte, error) {
+	body := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if key != "apiVersion" {
+			body[key] = value
+		}
+	}
+	data, err := yaml.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "apiVersion: %s\n", settings["apiVersion"])
+	buffer.Write(data)
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"NaNameUz3r/ReviewReaper/forge"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	durationPattern = `^([0-9]+d)?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))*$`
+	hhmmPattern     = `^([01][0-9]|2[0-3]):[0-5][0-9]$`
+)
+
+// durationKeys are parsed with ParseDuration, like "1d12h".
+var durationKeys = []string{
+	"Retention",
+	"DeletionNap",
+	"Quarantine.Duration",
+	"Backup.Retention",
+	"TerminationWatch.Timeout",
+	"Throttle.MinNap",
+	"Throttle.MaxNap",
+	"Budget.CheckInterval",
+	"Admission.MaxTTL",
+	"Teardown.StageTimeout",
+}
+
+type configKey struct {
+	name         string
+	defaultValue interface{}
+}
+
+// keyRecorder records the keys set by setDefaults in their documented case.
+type keyRecorder struct {
+	keys []configKey
+}
+
+func (r *keyRecorder) SetDefault(key string, value interface{}) {
+	r.keys = append(r.keys, configKey{name: key, defaultValue: value})
+}
+
+func knownKeys() []configKey {
+	recorder := &keyRecorder{}
+	setDefaults(recorder)
+	return recorder.keys
+}
+
+// checkUnknownKeys rejects keys of the config file which are not config keys, case-insensitively
+// as viper reads them, so typos do not silently fall back to defaults.
+func checkUnknownKeys(fileSettings map[string]interface{}) error {
+	known := map[string]bool{"apiversion": true}
+	for _, key := range knownKeys() {
+		known[strings.ToLower(key.name)] = true
+	}
+
+	errs := make([]error, 0)
+	for _, key := range flattenKeys(fileSettings, "") {
+		if known[strings.ToLower(key)] {
+			continue
+		}
+		if renamed, ok := renamedKeys[strings.ToLower(key)]; ok {
+			errs = append(errs, fmt.Errorf("Config key %s is renamed to %s in %s", key, renamed, CONFIG_API_VERSION))
+		} else if renamed, ok := unitKeys[strings.ToLower(key)]; ok {
+			errs = append(errs, fmt.Errorf("Config key %s is renamed to %s in %s and takes a duration", key, renamed.name, CONFIG_API_VERSION))
+		} else if IsContains(legacyRetentionKeys, strings.ToLower(key)) {
+			errs = append(errs, fmt.Errorf("Config key %s is renamed to Retention in %s and takes a duration", key, CONFIG_API_VERSION))
+		} else if suggestion := closestKey(key); suggestion != "" {
+			errs = append(errs, fmt.Errorf("Unknown config key %s, did you mean %s?", key, suggestion))
+		} else {
+			errs = append(errs, fmt.Errorf("Unknown config key %s", key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func flattenKeys(settings map[string]interface{}, prefix string) []string {
+	keys := make([]string, 0, len(settings))
+	for key, value := range settings {
+		// Sections with all keys commented out do not set anything.
+		if value == nil {
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			keys = append(keys, flattenKeys(nested, prefix+key+".")...)
+			continue
+		}
+		keys = append(keys, prefix+key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// closestKey returns the known key within a few typos of the given one.
+func closestKey(key string) string {
+	closest := ""
+	bestDistance := 4
+	for _, known := range knownKeys() {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(known.name))
+		if distance < bestDistance {
+			closest = known.name
+			bestDistance = distance
+		}
+	}
+	return closest
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = minOf(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func minOf(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
+
+// ConfigSchema returns the JSON Schema of the config file, built from the config keys and their defaults.
+// Keys are in the documented case, although ReviewReaper itself reads them case-insensitively.
+func ConfigSchema() ([]byte, error) {
+	root := objectSchema()
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "ReviewReaper config"
+	root["required"] = []string{"apiVersion", "NsNameDeletionRegexp"}
+	root["properties"].(map[string]interface{})["apiVersion"] = map[string]interface{}{
+		"type": "string",
+		"enum": []string{CONFIG_API_VERSION},
+	}
+
+	for _, key := range knownKeys() {
+		path := strings.Split(key.name, ".")
+		parent := root
+		for _, section := range path[:len(path)-1] {
+			properties := parent["properties"].(map[string]interface{})
+			if _, ok := properties[section]; !ok {
+				properties[section] = objectSchema()
+			}
+			parent = properties[section].(map[string]interface{})
+		}
+		parent["properties"].(map[string]interface{})[path[len(path)-1]] = keySchema(key)
+	}
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func objectSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           map[string]interface{}{},
+	}
+}
+
+func keySchema(key configKey) map[string]interface{} {
+	schema := map[string]interface{}{"default": key.defaultValue}
+	switch key.defaultValue.(type) {
+	case bool:
+		schema["type"] = "boolean"
+	case int:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case float64:
+		schema["type"] = "number"
+	case []string:
+		schema["type"] = "array"
+		schema["items"] = map[string]interface{}{"type": "string"}
+	case [][]string:
+		schema["type"] = "array"
+		schema["items"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	default:
+		schema["type"] = "string"
+	}
+
+	switch {
+	case key.name == "NsNameDeletionRegexp":
+		delete(schema, "default")
+	case IsContains(durationKeys, key.name):
+		schema["pattern"] = durationPattern
+	case strings.HasSuffix(key.name, ".WeekDays"):
+		schema["items"] = map[string]interface{}{"type": "string", "enum": defaultWeekDays}
+	case strings.HasSuffix(key.name, ".NotBefore") || strings.HasSuffix(key.name, ".NotAfter"):
+		schema["pattern"] = hhmmPattern
+	case key.name == "Teardown.PropagationPolicy":
+		schema["enum"] = []string{"", "Background", "Foreground", "Orphan"}
+	case key.name == "Forge.Type":
+		schema["description"] = fmt.Sprintf("One of %s", strings.Join(forge.KnownTypes, ", "))
+	case key.name == "OrphanCleanup.Mode":
+		schema["description"] = "One of delete, report"
+	case key.name == "Quota.Strategy":
+		schema["description"] = fmt.Sprintf("One of %s", strings.Join(QuotaStrategies, ", "))
+	case key.name == "Budget.Strategy":
+		schema["description"] = fmt.Sprintf("One of %s", strings.Join(BudgetStrategies, ", "))
+	}
+	return schema
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ENV_PREFIX prefixes env vars overriding config keys, like REVIEWREAPER_DELETIONWINDOW_NOTBEFORE.
+const ENV_PREFIX = "REVIEWREAPER"
+
+const (
+	CONFIG_SOURCE_FLAG    = "flag"
+	CONFIG_SOURCE_ENV     = "env"
+	CONFIG_SOURCE_FILE    = "file"
+	CONFIG_SOURCE_DEFAULT = "default"
+)
+
+// ConfigSource tells where the effective value of a config key comes from,
+// in the order of precedence: flags, env, file, defaults.
+type ConfigSource struct {
+	Key    string
+	EnvVar string
+	Source string
+}
+
+var boundFlags = map[string]*pflag.Flag{}
+
+// BindFlag overrides the config key with the flag, when the flag is set.
+func BindFlag(key string, flag *pflag.Flag) error {
+	boundFlags[strings.ToLower(key)] = flag
+	return viper.BindPFlag(key, flag)
+}
+
+// EnvVar returns the name of the env var overriding the config key.
+func EnvVar(key string) string {
+	return ENV_PREFIX + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ConfigSources returns sources of all known config keys, sorted by key.
+func ConfigSources() []ConfigSource {
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+
+	sources := make([]ConfigSource, 0, len(keys))
+	for _, key := range keys {
+		envVar := EnvVar(key)
+		if legacyEnvVar, ok := legacyEnvVars[key]; ok {
+			envVar = legacyEnvVar
+		}
+		sources = append(sources, ConfigSource{Key: key, EnvVar: envVar, Source: configSource(key)})
+	}
+	return sources
+}
+
+func configSource(key string) string {
+	if flag, ok := boundFlags[key]; ok && flag.Changed {
+		return CONFIG_SOURCE_FLAG
+	}
+	// Empty env vars are ignored by viper.
+	if _, ok := legacyEnvVars[key]; ok || os.Getenv(EnvVar(key)) != "" {
+		return CONFIG_SOURCE_ENV
+	}
+	if viper.InConfig(key) {
+		return CONFIG_SOURCE_FILE
+	}
+	return CONFIG_SOURCE_DEFAULT
+}
+
+// ConfigDeprecations returns a warning for every legacy key of the loaded config file and env vars.
+func ConfigDeprecations() []string {
+	return append([]string{}, configDeprecations...)
+}
+
+// getStringSlice splits lists passed as env vars by commas or spaces, like "Mon,Tue" or "Mon Tue".
+func getStringSlice(v *viper.Viper, key string) []string {
+	if value, ok := v.Get(key).(string); ok {
+		return strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+	}
+	return v.GetStringSlice(key)
+}
+
+// getInt, getBool and getFloat64 collect the values which are not of the type, viper would
+// silently read them as zero, e.g. REVIEWREAPER_QUOTA_MAXTOTAL=abc.
+func getInt(v *viper.Viper, key string, errs *[]error) int {
+	value, err := cast.ToIntE(v.Get(key))
+	if err != nil {
+		*errs = append(*errs, invalidTypeError(v, key, "an integer"))
+	}
+	return value
+}
+
+func getBool(v *viper.Viper, key string, errs *[]error) bool {
+	value, err := cast.ToBoolE(v.Get(key))
+	if err != nil {
+		*errs = append(*errs, invalidTypeError(v, key, "a boolean"))
+	}
+	return value
+}
+
+func getFloat64(v *viper.Viper, key string, errs *[]error) float64 {
+	value, err := cast.ToFloat64E(v.Get(key))
+	if err != nil {
+		*errs = append(*errs, invalidTypeError(v, key, "a number"))
+	}
+	return value
+}
+
+// getDuration reads a non-negative duration like "1d12h". The keys holding a bare number of hours,
+// days or seconds are renamed by migrate-config.
+func getDuration(v *viper.Viper, key string, errs *[]error) time.Duration {
+	duration, err := ParseDuration(v.GetString(key))
+	if err != nil || duration < 0 {
+		*errs = append(*errs, invalidTypeError(v, key, "a non-negative duration like 1d12h"))
+	}
+	return duration
+}
+
+func invalidTypeError(v *viper.Viper, key string, kind string) error {
+	if value, ok := os.LookupEnv(EnvVar(key)); ok && value != "" {
+		return fmt.Errorf("%s should be %s, got %q from %s", key, kind, value, EnvVar(key))
+	}
+	return fmt.Errorf("%s should be %s, got %q", key, kind, fmt.Sprint(v.Get(key)))
+}
@@ -0,0 +1,348 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const testConfig = `apiVersion: reviewreaper/v1
+NsNameDeletionRegexp: feature
+Retention: 1d
+`
+
+// loadTestConfig writes the config into a temp dir and loads it with a clean global viper.
+func loadTestConfig(t *testing.T, content string) (Config, error) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfigFile(path)
+}
+
+func TestWebhookSecretFile(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		webhook string
+		secret  string
+		err     string
+	}{
+		{
+			name:    "secret file",
+			webhook: "Webhook:\n  Enabled: true\n  SecretFile: " + secretFile + "\n",
+			secret:  "s3cr3t",
+		},
+		{
+			name:    "file wins over the config",
+			webhook: "Webhook:\n  Enabled: true\n  Secret: leaked\n  SecretFile: " + secretFile + "\n",
+			secret:  "s3cr3t",
+		},
+		{
+			name:    "missing file",
+			webhook: "Webhook:\n  Enabled: true\n  SecretFile: " + secretFile + ".missing\n",
+			err:     "Unable to read Webhook.SecretFile",
+		},
+		{
+			name:    "no secret",
+			webhook: "Webhook:\n  Enabled: true\n",
+			err:     errWebhookSecretMissing.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadTestConfig(t, testConfig+tt.webhook)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Webhook.Secret != tt.secret {
+				t.Errorf("expected secret %q, got %q", tt.secret, config.Webhook.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookSecretEnv(t *testing.T) {
+	t.Setenv("REVIEWREAPER_WEBHOOK_SECRET", "from-env")
+	config, err := loadTestConfig(t, testConfig+"Webhook:\n  Enabled: true\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Webhook.Secret != "from-env" {
+		t.Errorf("expected secret from the environment, got %q", config.Webhook.Secret)
+	}
+}
+
+// TestWatchConfigReload changes the file while the previous change is being reloaded, so the viper
+// watcher reads the file at the same time. Run it with -race.
+func TestWatchConfigReload(t *testing.T) {
+	if _, err := loadTestConfig(t, testConfig); err != nil {
+		t.Fatal(err)
+	}
+	path := viper.ConfigFileUsed()
+	reloaded := make(chan Config, 10)
+	WatchConfig(func(config Config, err error) {
+		if err != nil {
+			config.NsNameDeletionRegexp = err.Error()
+		}
+		reloaded <- config
+	})
+
+	for _, retention := range []string{"2d", "3d", "4d"} {
+		// The file is replaced atomically like a ConfigMap, a reload must not see it half-written.
+		changed := strings.Replace(testConfig, "Retention: 1d", "Retention: "+retention, 1)
+		if err := os.WriteFile(path+".tmp", []byte(changed), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(configReloadDelay)
+	}
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case config := <-reloaded:
+			if config.NsNameDeletionRegexp != "feature" {
+				t.Fatalf("unexpected reload error: %s", config.NsNameDeletionRegexp)
+			}
+			if config.Retention == 4*24*time.Hour {
+				return
+			}
+		case <-timeout:
+			t.Fatal("config was not reloaded")
+		}
+	}
+}
+
+func TestMalformedValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		config string
+		err    string
+	}{
+		{
+			name: "integer env",
+			env:  map[string]string{"REVIEWREAPER_QUOTA_MAXTOTAL": "abc"},
+			err:  `Quota.MaxTotal should be an integer, got "abc" from REVIEWREAPER_QUOTA_MAXTOTAL`,
+		},
+		{
+			name: "boolean env",
+			env:  map[string]string{"REVIEWREAPER_DRYRUN": "maybe"},
+			err:  `DryRun should be a boolean, got "maybe" from REVIEWREAPER_DRYRUN`,
+		},
+		{
+			name: "number env",
+			env:  map[string]string{"REVIEWREAPER_THROTTLE_METRICQUERY_THRESHOLD": "high"},
+			err:  `Throttle.MetricQuery.Threshold should be a number, got "high"`,
+		},
+		{
+			name:   "integer in file",
+			config: "DeletionBatchSize: ten\n",
+			err:    `DeletionBatchSize should be an integer, got "ten"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := loadTestConfig(t, testConfig+tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestWellFormedEnvValues(t *testing.T) {
+	t.Setenv("REVIEWREAPER_QUOTA_MAXTOTAL", "5")
+	t.Setenv("REVIEWREAPER_DRYRUN", "true")
+	config, err := loadTestConfig(t, testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Quota.MaxTotal != 5 || !config.DryRun {
+		t.Errorf("expected Quota.MaxTotal 5 and DryRun from the environment, got %d and %v", config.Quota.MaxTotal, config.DryRun)
+	}
+}
+
+func TestDurationKeys(t *testing.T) {
+	config, err := loadTestConfig(t, testConfig+`
+Quarantine:
+  Duration: 1d12h
+Backup:
+  Retention: 14d
+TerminationWatch:
+  Timeout: 90s
+Throttle:
+  MinNap: 10s
+  MaxNap: 2m
+Teardown:
+  StageTimeout: 45s
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	durations := map[string][2]time.Duration{
+		"Quarantine.Duration":      {config.Quarantine.Duration, 36 * time.Hour},
+		"Backup.Retention":         {config.Backup.Retention, 14 * 24 * time.Hour},
+		"TerminationWatch.Timeout": {config.TerminationWatch.Timeout, 90 * time.Second},
+		"Throttle.MinNap":          {config.Throttle.MinNap, 10 * time.Second},
+		"Throttle.MaxNap":          {config.Throttle.MaxNap, 2 * time.Minute},
+		"Teardown.StageTimeout":    {config.Teardown.StageTimeout, 45 * time.Second},
+		"Budget.CheckInterval":     {config.Budget.CheckInterval, 5 * time.Minute},
+	}
+	for key, duration := range durations {
+		if duration[0] != duration[1] {
+			t.Errorf("expected %s %s, got %s", key, duration[1], duration[0])
+		}
+	}
+}
+
+func TestInvalidDurationKeys(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{
+			config: "Quarantine:\n  Duration: soon\n",
+			err:    `Quarantine.Duration should be a non-negative duration like 1d12h, got "soon"`,
+		},
+		{
+			config: "Backup:\n  Retention: -1d\n",
+			err:    `Backup.Retention should be a non-negative duration like 1d12h, got "-1d"`,
+		},
+		{
+			config: "Budget:\n  Enabled: true\n  CPU: \"4\"\n  CheckInterval: 0s\n",
+			err:    errBudgetIntervalInvalid.Error(),
+		},
+		{
+			config: "Throttle:\n  Adaptive: true\n  MinNap: 1m\n  MaxNap: 30s\n",
+			err:    errThrottleBoundsInvalid.Error(),
+		},
+	}
+	for _, tt := range tests {
+		_, err := loadTestConfig(t, testConfig+tt.config)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected error %q, got %v", tt.err, err)
+		}
+	}
+}
+
+func TestRenamedDurationKeys(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{
+			config: "Quarantine:\n  Hours: 24\n",
+			err:    "Config key Quarantine.Hours is renamed to Quarantine.Duration in reviewreaper/v1 and takes a duration",
+		},
+		{
+			config: "DeletionNapSeconds: 30\n",
+			err:    "Config key DeletionNapSeconds is renamed to DeletionNap in reviewreaper/v1 and takes a duration",
+		},
+		{
+			config: "IsUninstallReleases: true\n",
+			err:    "Config key IsUninstallReleases is renamed to UninstallReleases in reviewreaper/v1",
+		},
+	}
+	for _, tt := range tests {
+		_, err := loadTestConfig(t, testConfig+tt.config)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected error %q, got %v", tt.err, err)
+		}
+	}
+
+	// The legacy retention replaces Retention of the test config.
+	_, err := loadTestConfig(t, "apiVersion: reviewreaper/v1\nRetention:\n  Days: 1\n  Hours: 2\n")
+	for _, expected := range []string{
+		"Config key Retention.Days is renamed to Retention in reviewreaper/v1 and takes a duration",
+		"Config key Retention.Hours is renamed to Retention in reviewreaper/v1 and takes a duration",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestRunOnceServers(t *testing.T) {
+	servers := `
+Webhook:
+  Enabled: true
+  Secret: secret
+AdminAPI:
+  Enabled: true
+  Token: token
+`
+	if _, err := loadTestConfig(t, testConfig+servers); err != nil {
+		t.Fatalf("expected servers to be valid without RunOnce, got %v", err)
+	}
+
+	_, err := loadTestConfig(t, testConfig+"RunOnce: true\n"+servers)
+	for _, expected := range []string{"Webhook.Enabled " + errRunOnceServerEnabled.Error(), "AdminAPI.Enabled " + errRunOnceServerEnabled.Error()} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestLegacyDeprecations(t *testing.T) {
+	t.Setenv("REVIEWREAPER_THROTTLE_MINNAPSECONDS", "10")
+	t.Setenv("REVIEWREAPER_RETENTION_DAYS", "2")
+	t.Setenv("REVIEWREAPER_DELETIONNAPSECONDS", "5")
+	t.Setenv("REVIEWREAPER_DELETIONNAP", "1s")
+	config, err := loadTestConfig(t, `
+NsNameDeletionRegexp: feature
+IsUninstallReleases: true
+Quarantine:
+  Hours: 36
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Config key apiVersion is missing, set apiVersion: reviewreaper/v1 or run review-reaper migrate-config",
+		"Config key IsUninstallReleases is deprecated, use UninstallReleases: true",
+		"Config key Quarantine.Hours is deprecated, use Quarantine.Duration: 1d12h",
+		"Env var REVIEWREAPER_DELETIONNAPSECONDS is deprecated and ignored, REVIEWREAPER_DELETIONNAP is set",
+		"Env var REVIEWREAPER_RETENTION_DAYS is deprecated, use REVIEWREAPER_RETENTION=2d",
+		"Env var REVIEWREAPER_THROTTLE_MINNAPSECONDS is deprecated, use REVIEWREAPER_THROTTLE_MINNAP=10s",
+	}
+	if warnings := ConfigDeprecations(); strings.Join(warnings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected warnings\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(warnings, "\n"))
+	}
+	if config.Retention != 48*time.Hour || config.Throttle.MinNap != 10*time.Second || config.DeletionNap != time.Second {
+		t.Errorf("expected the legacy env vars to be read, got Retention %s, Throttle.MinNap %s and DeletionNap %s",
+			config.Retention, config.Throttle.MinNap, config.DeletionNap)
+	}
+
+	for _, source := range ConfigSources() {
+		if source.Key == "throttle.minnap" && (source.Source != CONFIG_SOURCE_ENV || source.EnvVar != "REVIEWREAPER_THROTTLE_MINNAPSECONDS") {
+			t.Errorf("expected throttle.minnap from the legacy env var, got %+v", source)
+		}
+	}
+}
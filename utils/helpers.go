@@ -1,5 +1,12 @@
 package utils
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 func IsContains[T comparable](elements []T, findThis T) bool {
 	found := false
 	for _, element := range elements {
@@ -9,3 +16,50 @@ func IsContains[T comparable](elements []T, findThis T) bool {
 	}
 	return found
 }
+
+// ParseDuration extends time.ParseDuration with days, like "2d" or "1d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	days, rest, hasDays := strings.Cut(s, "d")
+	if !hasDays {
+		return time.ParseDuration(s)
+	}
+
+	daysCount, err := strconv.Atoi(days)
+	if err != nil {
+		return 0, fmt.Errorf("time: invalid duration %q", s)
+	}
+	duration := time.Duration(daysCount) * 24 * time.Hour
+	if rest == "" {
+		return duration, nil
+	}
+
+	restDuration, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("time: invalid duration %q", s)
+	}
+	return duration + restDuration, nil
+}
+
+// FormatDuration is the reverse of ParseDuration, it prints whole days as a "d" prefix
+// and drops zero minutes and seconds, like "3d12h".
+func FormatDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+
+	rest := d
+	days := ""
+	if d > day {
+		days = fmt.Sprintf("%dd", d/day)
+		rest = d % day
+	}
+	formatted := rest.String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+	return days + formatted
+}